@@ -14,6 +14,8 @@ type Aggregate struct {
 	fn    string
 	arg   string
 	alias string
+	// distinct 例如 COUNT(DISTINCT `col`)
+	distinct bool
 }
 
 func (a Aggregate) expr() {}
@@ -33,11 +35,8 @@ func (a Aggregate) target() TableReference {
 }
 
 func (a Aggregate) As(alias string) Aggregate {
-	return Aggregate{
-		fn:    a.fn,
-		arg:   a.arg,
-		alias: alias,
-	}
+	a.alias = alias
+	return a
 }
 
 // EQ 例如 C("id").Eq(12)
@@ -65,6 +64,47 @@ func (a Aggregate) GT(arg any) Predicate {
 	}
 }
 
+func (a Aggregate) NEQ(arg any) Predicate {
+	return newPredicate(a, opNEQ, arg)
+}
+
+func (a Aggregate) LTE(arg any) Predicate {
+	return newPredicate(a, opLTE, arg)
+}
+
+func (a Aggregate) GTE(arg any) Predicate {
+	return newPredicate(a, opGTE, arg)
+}
+
+func (a Aggregate) Like(pattern any) Predicate {
+	return newPredicate(a, opLike, pattern)
+}
+
+func (a Aggregate) NotLike(pattern any) Predicate {
+	return newPredicate(a, opNotLike, pattern)
+}
+
+// Between 例如 Avg("Age").Between(18, 35)，包含两端
+func (a Aggregate) Between(lo, hi any) Predicate {
+	return newBetween(a, lo, hi)
+}
+
+func (a Aggregate) In(vals ...any) Predicate {
+	return newIn(a, opIN, vals)
+}
+
+func (a Aggregate) NotIn(vals ...any) Predicate {
+	return newIn(a, opNotIN, vals)
+}
+
+func (a Aggregate) IsNull() Predicate {
+	return newNullPredicate(a, opIsNull)
+}
+
+func (a Aggregate) IsNotNull() Predicate {
+	return newNullPredicate(a, opIsNotNull)
+}
+
 func Avg(col string) Aggregate {
 	return Aggregate{
 		arg: col,
@@ -99,3 +139,30 @@ func Sum(col string) Aggregate {
 		fn:  sum,
 	}
 }
+
+// CountDistinct 例如 COUNT(DISTINCT `user_id`)
+func CountDistinct(col string) Aggregate {
+	return Aggregate{
+		arg:      col,
+		fn:       count,
+		distinct: true,
+	}
+}
+
+// SumDistinct 例如 SUM(DISTINCT `price`)
+func SumDistinct(col string) Aggregate {
+	return Aggregate{
+		arg:      col,
+		fn:       sum,
+		distinct: true,
+	}
+}
+
+// AvgDistinct 例如 AVG(DISTINCT `price`)
+func AvgDistinct(col string) Aggregate {
+	return Aggregate{
+		arg:      col,
+		fn:       avg,
+		distinct: true,
+	}
+}
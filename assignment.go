@@ -9,7 +9,9 @@ type Assignable interface {
 
 type Assignment struct {
 	column string
-	val    Expression
+	// table 列所在的表，为 nil 的时候使用更新的表
+	table TableReference
+	val   Expression
 }
 
 func Assign(column string, val any) Assignment {
@@ -23,4 +25,11 @@ func Assign(column string, val any) Assignment {
 	}
 }
 
+// Assign 给表 t 的列赋值，用于 UPDATE ... JOIN 里面更新指定的表
+func (t Table) Assign(column string, val any) Assignment {
+	a := Assign(column, val)
+	a.table = t
+	return a
+}
+
 func (a Assignment) assign() {}
@@ -18,8 +18,32 @@ type Builder struct {
 	// as 别名映射
 	aliasMap map[string]int
 
-	//dialect Dialect
-	quoter byte
+	// argsOffset 在这之前已经有多少个参数了。
+	// 构造子查询的时候，子查询的参数会被拼接到外部查询的参数后面，
+	// 对于 PostgreSQL 这种使用 $1 这种带编号占位符的数据库来说，
+	// 子查询需要知道自己的编号从哪里开始
+	argsOffset int
+
+	// withs 是 WITH 里面定义的 CTE
+	withs []commonTable
+	// ctes 当前可见的 CTE，用于解析 CommonTable 的列名
+	ctes map[string]Subquery
+	// upsert 正在构造的 upsert 语句，构造 Excluded 的时候需要
+	upsert *Upsert
+	// assignTables UPDATE ... JOIN 里面的表，用来解析赋值语句的列
+	assignTables []Table
+	// qualifier 不为空的时候，没有指定表的列都会加上它作为前缀，
+	// 例如 PostgreSQL 的 DO UPDATE 里面需要区分目标表和 EXCLUDED
+	qualifier string
+}
+
+// argsOffsetSetter 内嵌了 Builder 的 QueryBuilder 都实现了该接口
+type argsOffsetSetter interface {
+	setArgsOffset(offset int)
+}
+
+func (b *Builder) setArgsOffset(offset int) {
+	b.argsOffset = offset
 }
 
 func (b *Builder) writeSpace() {
@@ -30,8 +54,9 @@ func (b *Builder) writeComma() {
 	b.writeByte(',')
 }
 
+// writePlaceholder 写入占位符，注意必须在 addArgs 之前调用
 func (b *Builder) writePlaceholder() {
-	b.writeByte('?')
+	b.writeString(b.dialect.Placeholder(b.argsOffset + len(b.args) + 1))
 }
 
 func (b *Builder) writeLeftParenthesis() {
@@ -43,16 +68,29 @@ func (b *Builder) writeRightParenthesis() {
 }
 
 func (b *Builder) quote(name string) {
-	b.writeByte(b.quoter)
+	left, right := b.dialect.Quoter()
+	b.writeByte(left)
 	b.writeString(name)
-	b.writeByte(b.quoter)
-
+	b.writeByte(right)
 }
 
 func (b *Builder) end() {
 	b.writeString(";")
 }
 
+// release 在 Build 结束之后把 buffer 放回池子里面。
+// 同一个 QueryBuilder 有可能被构造多次，例如同一个子查询出现在两个地方，
+// 所以要换上新的 buffer，避免同一个 buffer 被放回两次之后同时被两个 Builder 使用，
+// 并且清空参数和别名，下一次构造的时候不会重复
+func (b *Builder) release() {
+	bytebufferpool.Put(b.buffer)
+	b.buffer = new(bytebufferpool.ByteBuffer)
+	b.args = nil
+	for alias := range b.aliasMap {
+		delete(b.aliasMap, alias)
+	}
+}
+
 func (b *Builder) writeString(val string) {
 	_, _ = b.buffer.WriteString(val)
 }
@@ -62,15 +100,89 @@ func (b *Builder) writeByte(c byte) {
 }
 
 func (b *Builder) buildAssignment(a Assignment) error {
-	fd, ok := b.model.FieldMap[a.column]
-	if !ok {
-		return errs.NewErrUnknownField(a.column)
+	if _, err := b.buildAssignColumn(a.table, a.column); err != nil {
+		return err
 	}
-	b.quote(fd.ColName)
 	b.writeString(" = ")
 	return b.buildExpression(a.val, false, false)
 }
 
+// buildAssignColumn 构造赋值语句左边的列，并返回对应的字段。
+// 指定了表，或者更新的是 JOIN 的时候，会在列名前面加上表的别名或者表名；
+// 没有指定表的时候按照 JOIN 的顺序使用第一个有该字段的表
+func (b *Builder) buildAssignColumn(table TableReference, name string) (*model.Field, error) {
+	if table == nil && len(b.assignTables) == 0 {
+		fd, ok := b.model.FieldMap[name]
+		if !ok {
+			return nil, errs.NewErrUnknownField(name)
+		}
+		b.quote(fd.ColName)
+		return fd, nil
+	}
+	tables := b.assignTables
+	if table != nil {
+		tbl, ok := table.(Table)
+		if !ok {
+			return nil, errs.NewErrUnsupportedExpressionType(table)
+		}
+		tables = []Table{tbl}
+	}
+	for _, tbl := range tables {
+		m, err := b.r.Get(tbl.entity)
+		if err != nil {
+			return nil, err
+		}
+		fd, ok := m.FieldMap[name]
+		if !ok {
+			continue
+		}
+		alias := tbl.alias
+		if alias == "" {
+			alias = m.TableName
+		}
+		b.quote(alias)
+		b.writeByte('.')
+		b.quote(fd.ColName)
+		return fd, nil
+	}
+	return nil, errs.NewErrUnknownField(name)
+}
+
+// buildExcluded 构造 upsert 中对待插入的值的引用
+func (b *Builder) buildExcluded(e ExcludedExpr) error {
+	if b.upsert == nil {
+		return errs.ErrExcludedOutsideUpsert
+	}
+	fd, ok := b.model.FieldMap[e.name]
+	if !ok {
+		return errs.NewErrUnknownField(e.name)
+	}
+	return b.dialect.BuildExcluded(b, b.upsert, fd)
+}
+
+// buildConflictAssigns 构造冲突时的更新部分，
+// Column 相当于 Assign(name, Excluded(name))
+func (b *Builder) buildConflictAssigns(assigns []Assignable) error {
+	for pos, assign := range assigns {
+		if pos > 0 {
+			b.writeComma()
+		}
+		switch a := assign.(type) {
+		case Assignment:
+			if err := b.buildAssignment(a); err != nil {
+				return err
+			}
+		case Column:
+			if err := b.buildAssignment(Assign(a.name, Excluded(a.name))); err != nil {
+				return err
+			}
+		default:
+			return errs.NewErrUnsupportedAssignableType(assign)
+		}
+	}
+	return nil
+}
+
 func (b *Builder) buildPredicates(pres *predicates) error {
 	ps := pres.ps[0]
 	for i := 1; i < len(pres.ps); i++ {
@@ -144,21 +256,106 @@ func (b *Builder) colName(table TableReference, fdName string, useAlias bool) (s
 					return fdName, nil
 				}
 				if col.fieldName() == fdName {
-					return b.colName(col.target(), fdName, useAlias)
+					// 没有指定表的列属于子查询的表
+					target := col.target()
+					if tbl, ok := tab.table.(Table); ok && target == nil {
+						target = tbl
+					}
+					return b.colName(target, fdName, useAlias)
 				}
-				return "", errs.NewErrUnknownField(fdName)
 			}
+			return "", errs.NewErrUnknownField(fdName)
 		}
 		return b.colName(tab.table, fdName, useAlias)
+	case CommonTable:
+		sub, err := b.cteOf(tab)
+		if err != nil {
+			return "", err
+		}
+		return b.colName(sub, fdName, useAlias)
 	default:
 		return "", errs.NewErrUnsupportedExpressionType(tab)
 	}
 }
 
+func (b *Builder) buildJoin(join Join) error {
+	b.writeLeftParenthesis()
+	if err := b.buildTable(join.left); err != nil {
+		return err
+	}
+	b.writeSpace()
+	b.writeString(join.typ)
+	b.writeSpace()
+	if err := b.buildTable(join.right); err != nil {
+		return err
+	}
+	if len(join.using) > 0 {
+		b.writeString(" USING ")
+		b.writeLeftParenthesis()
+		for i, col := range join.using {
+			if i > 0 {
+				b.writeComma()
+			}
+			err := b.buildColumn(Column{name: col}, false)
+			if err != nil {
+				return err
+			}
+		}
+		b.writeRightParenthesis()
+	}
+	if join.on != nil && len(join.on.ps) > 0 {
+		b.writeString(" ON ")
+		err := b.buildPredicates(join.on)
+		if err != nil {
+			return err
+		}
+	}
+	b.writeRightParenthesis()
+	return nil
+}
+
+// buildTable 构造 FROM 或者 UPDATE 后面的表，table 为 nil 的时候使用模型的表名
+func (b *Builder) buildTable(table TableReference) error {
+	switch tab := table.(type) {
+	case nil:
+		b.quote(b.model.TableName)
+	case Table:
+		meta, err := b.r.Get(tab.entity)
+		if err != nil {
+			return err
+		}
+		b.quote(meta.TableName)
+		return b.buildAs(tab.alias)
+	case Join:
+		return b.buildJoin(tab)
+	case Subquery:
+		return b.buildSubquery(tab, true)
+	case CommonTable:
+		if _, err := b.cteOf(tab); err != nil {
+			return err
+		}
+		b.quote(tab.name)
+		return b.buildAs(tab.alias)
+	default:
+		return errs.NewErrUnsupportedExpressionType(tab)
+	}
+	return nil
+}
+
 func (b *Builder) buildColumn(val Column, useAlias bool) error {
 	var alias string
 	if val.table != nil {
 		alias = val.table.tableAlias()
+		// 没有别名的表使用表名限定列，否则 JOIN 里面的同名列会有歧义
+		if tbl, ok := val.table.(Table); ok && alias == "" {
+			m, err := b.r.Get(tbl.entity)
+			if err != nil {
+				return err
+			}
+			alias = m.TableName
+		}
+	} else if !useAlias || b.aliasMap[val.name] == 0 {
+		alias = b.qualifier
 	}
 	if alias != "" {
 		b.quote(alias)
@@ -175,6 +372,9 @@ func (b *Builder) buildColumn(val Column, useAlias bool) error {
 func (b *Builder) buildAggregate(val Aggregate, useAlias bool) error {
 	b.writeString(val.fn)
 	b.writeLeftParenthesis()
+	if val.distinct {
+		b.writeString("DISTINCT ")
+	}
 	err := b.buildColumn(
 		Column{table: val.table, name: val.arg}, useAlias)
 	if err != nil {
@@ -191,12 +391,16 @@ func (b *Builder) buildAggregate(val Aggregate, useAlias bool) error {
 }
 
 func (b *Builder) buildSubquery(sub Subquery, useAlias bool) error {
+	if setter, ok := sub.s.(argsOffsetSetter); ok {
+		setter.setArgsOffset(b.argsOffset + len(b.args))
+	}
+	b.passCTEs(sub.s)
 	q, err := sub.s.Build()
 	if err != nil {
 		return err
 	}
 	b.writeLeftParenthesis()
-	b.writeString(q.SQL[:len(q.SQL)-1])
+	b.writeString(trimEnd(q.SQL))
 	b.writeRightParenthesis()
 	if len(q.Args) > 0 {
 		b.addArgs(q.Args...)
@@ -211,6 +415,11 @@ func (b *Builder) buildSubquery(sub Subquery, useAlias bool) error {
 
 func (b *Builder) buildBinaryExpr(
 	exp binaryExpr, colsAlias, aggreAlias bool) error {
+	// IN () 是非法的语法，空列表的 IN 永远为假，NOT IN 永远为真。
+	// 但是左边依旧需要校验
+	if in, ok := exp.right.(inValues); ok && len(in.vals) == 0 {
+		return b.buildEmptyIn(exp)
+	}
 	err := b.buildSubExpr(
 		exp.left, colsAlias, aggreAlias)
 	if err != nil {
@@ -219,13 +428,163 @@ func (b *Builder) buildBinaryExpr(
 	if exp.op != "" {
 		b.writeSpace()
 		b.writeString(exp.op.String())
+		// IS NULL 这种一元操作符没有右边
+		if exp.right == nil {
+			return nil
+		}
 		b.writeSpace()
 	}
-
+	// BETWEEN 的右边 lo AND hi 是一个整体，不能加括号
+	if bound, ok := exp.right.(binaryExpr); ok && exp.op == opBetween {
+		return b.buildBinaryExpr(bound, colsAlias, aggreAlias)
+	}
 	return b.buildSubExpr(
 		exp.right, colsAlias, aggreAlias)
 }
 
+func (b *Builder) buildEmptyIn(exp binaryExpr) error {
+	// 使用一个临时的 buffer 校验左边，校验完之后丢弃
+	buffer, args := b.buffer, b.args
+	b.buffer = bytebufferpool.Get()
+	err := b.buildSubExpr(exp.left, false, false)
+	bytebufferpool.Put(b.buffer)
+	b.buffer, b.args = buffer, args
+	if err != nil {
+		return err
+	}
+	if exp.op == opNotIN {
+		b.writeString("1 = 1")
+	} else {
+		b.writeString("1 = 0")
+	}
+	return nil
+}
+
+func (b *Builder) buildFunc(f FuncExpr) error {
+	if f.builtin {
+		return b.dialect.BuildFunc(b, f)
+	}
+	return b.buildFuncCall(f.name, f.args...)
+}
+
+// buildFuncCall 构造 NAME(arg1,arg2)。
+// 参数里面不允许使用别名
+func (b *Builder) buildFuncCall(name string, args ...Expression) error {
+	b.writeString(name)
+	b.writeLeftParenthesis()
+	for i, arg := range args {
+		if i > 0 {
+			b.writeComma()
+		}
+		if err := b.buildExpression(arg, false, false); err != nil {
+			return err
+		}
+	}
+	b.writeRightParenthesis()
+	return nil
+}
+
+// buildCase 构造 CASE WHEN cond THEN val ELSE val END，
+// 参数按照出现的顺序加入
+func (b *Builder) buildCase(c CaseExpr, colsAlias bool) error {
+	if len(c.whens) == 0 {
+		return errs.ErrCaseWithoutWhen
+	}
+	b.writeString("CASE")
+	for _, w := range c.whens {
+		b.writeString(" WHEN ")
+		if err := b.buildExpression(w.cond, colsAlias, false); err != nil {
+			return err
+		}
+		b.writeString(" THEN ")
+		if err := b.buildSubExpr(w.val, colsAlias, false); err != nil {
+			return err
+		}
+	}
+	if c.els != nil {
+		b.writeString(" ELSE ")
+		if err := b.buildSubExpr(c.els, colsAlias, false); err != nil {
+			return err
+		}
+	}
+	b.writeString(" END")
+	return nil
+}
+
+// buildWindow 构造 fn OVER (PARTITION BY ... ORDER BY ...)，
+// 这里的别名由调用者处理
+func (b *Builder) buildWindow(w WindowExpr) error {
+	if err := b.buildExpression(w.fn, false, false); err != nil {
+		return err
+	}
+	b.writeString(" OVER (")
+	if len(w.partitionBy) > 0 {
+		b.writeString("PARTITION BY ")
+		for i, col := range w.partitionBy {
+			if i > 0 {
+				b.writeComma()
+			}
+			if err := b.buildExpression(col, false, false); err != nil {
+				return err
+			}
+		}
+	}
+	if len(w.orderBy) > 0 {
+		if len(w.partitionBy) > 0 {
+			b.writeSpace()
+		}
+		b.writeString("ORDER BY ")
+		if err := b.buildOrderBy(w.orderBy, false); err != nil {
+			return err
+		}
+	}
+	b.writeRightParenthesis()
+	return nil
+}
+
+// buildOrderBy 构造 ORDER BY 后面的部分。
+// useAlias 为 true 的时候可以使用 SELECT 里面的别名，
+// 聚合函数本身的别名不会输出
+func (b *Builder) buildOrderBy(orderBys []OrderBy, useAlias bool) error {
+	for i, od := range orderBys {
+		if i > 0 {
+			b.writeComma()
+		}
+		// 不支持 NULLS FIRST 的数据库，先按照是否为 NULL 排序
+		if od.nulls != "" && !b.dialect.SupportNullsOrder() {
+			b.writeString("CASE WHEN ")
+			if err := b.buildSubExpr(od.expr, useAlias, false); err != nil {
+				return err
+			}
+			if od.nulls == nullsFirst {
+				b.writeString(" IS NULL THEN 0 ELSE 1 END,")
+			} else {
+				b.writeString(" IS NULL THEN 1 ELSE 0 END,")
+			}
+		}
+		if err := b.buildSubExpr(od.expr, useAlias, false); err != nil {
+			return err
+		}
+		b.writeString(" " + od.order)
+		if od.nulls != "" && b.dialect.SupportNullsOrder() {
+			b.writeString(" " + od.nulls)
+		}
+	}
+	return nil
+}
+
+func (b *Builder) buildInValues(in inValues) {
+	b.writeLeftParenthesis()
+	for i, val := range in.vals {
+		if i > 0 {
+			b.writeComma()
+		}
+		b.writePlaceholder()
+		b.addArgs(val)
+	}
+	b.writeRightParenthesis()
+}
+
 func (b *Builder) buildSubExpr(expr Expression, colsAlias, aggreAlias bool) error {
 	switch e := expr.(type) {
 	case MathExpr:
@@ -281,13 +640,113 @@ func (b *Builder) buildExpression(
 	case Predicate:
 		return b.buildBinaryExpr(
 			binaryExpr(exp), colsAlias, aggreAlias)
+	case inValues:
+		b.buildInValues(exp)
+		return nil
+	case FuncExpr:
+		return b.buildFunc(exp)
+	case CaseExpr:
+		return b.buildCase(exp, colsAlias)
+	case WindowExpr:
+		return b.buildWindow(exp)
 	case Subquery:
 		return b.buildSubquery(exp, false)
 	case SubqueryExpr:
 		b.writeString(exp.pred)
 		b.writeSpace()
 		return b.buildSubquery(exp.s, false)
+	case ExcludedExpr:
+		return b.buildExcluded(exp)
 	default:
 		return errs.NewErrUnsupportedExpressionType(exp)
 	}
 }
+
+// 以下方法是提供给自定义 Dialect 使用的，
+// 内部实现依旧使用对应的私有方法
+
+// WriteString 直接写入 SQL 片段
+func (b *Builder) WriteString(val string) {
+	b.writeString(val)
+}
+
+// WriteByte 直接写入一个字节，总是返回 nil
+func (b *Builder) WriteByte(c byte) error {
+	b.writeByte(c)
+	return nil
+}
+
+// Quote 使用方言的引号引用 name
+func (b *Builder) Quote(name string) {
+	b.quote(name)
+}
+
+// WritePlaceholder 写入下一个参数的占位符，之后必须调用 AddArgs 加入对应的参数
+func (b *Builder) WritePlaceholder() {
+	b.writePlaceholder()
+}
+
+// AddArgs 加入参数
+func (b *Builder) AddArgs(args ...any) {
+	b.addArgs(args...)
+}
+
+// ColumnName 返回 c 对应的列名
+func (b *Builder) ColumnName(c Column) (string, error) {
+	return b.colName(c.table, c.name, false)
+}
+
+// BuildAssignment 构造 col = val 这种赋值语句
+func (b *Builder) BuildAssignment(a Assignment) error {
+	return b.buildAssignment(a)
+}
+
+// BuildInsert 构造 INSERT INTO table(col1,col2) VALUES(?,?),(?,?) 部分，
+// 大多数方言只需要在此之后追加冲突处理部分。
+//
+// Deprecated: 插入的是查询结果的时候，构造 SELECT 语句的错误会被忽略，请使用 BuildInsertInto
+func (b *Builder) BuildInsert(u *Upsert) {
+	_ = b.buildInsert("INSERT", u)
+}
+
+// BuildInsertInto 构造 INSERT INTO table(col1,col2) VALUES(?,?),(?,?) 部分，
+// 插入的是查询结果的时候 VALUES 部分是 SELECT 语句。
+// 大多数方言只需要在此之后追加冲突处理部分
+func (b *Builder) BuildInsertInto(u *Upsert) error {
+	return b.buildInsert("INSERT", u)
+}
+
+// BuildInsertSource 构造 VALUES(?,?),(?,?) 或者 SELECT ... 部分
+func (b *Builder) BuildInsertSource(u *Upsert) error {
+	return b.buildInsertSource(u)
+}
+
+// BuildConflictAssigns 构造冲突时的更新部分，即 col1 = ?,col2 = excluded.col2
+func (b *Builder) BuildConflictAssigns(assigns []Assignable) error {
+	return b.buildConflictAssigns(assigns)
+}
+
+// BuildPredicates 使用 AND 连接 ps 并构造出来
+func (b *Builder) BuildPredicates(ps []Predicate) error {
+	return b.buildPredicates(&predicates{ps: ps})
+}
+
+// BuildFieldList 构造 (col1,col2) 部分
+func (b *Builder) BuildFieldList(fields []*model.Field) {
+	b.buildFieldList(fields)
+}
+
+// BuildValueRows 构造 (?,?),(?,?) 部分，并加入对应的参数
+func (b *Builder) BuildValueRows(rows [][]any) {
+	b.buildValueRows(rows)
+}
+
+// BuildExpression 构造表达式，例如函数的参数
+func (b *Builder) BuildExpression(e Expression) error {
+	return b.buildExpression(e, false, false)
+}
+
+// BuildFuncCall 构造 NAME(arg1,arg2) 这种标准的函数调用
+func (b *Builder) BuildFuncCall(name string, args ...Expression) error {
+	return b.buildFuncCall(name, args...)
+}
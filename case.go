@@ -0,0 +1,67 @@
+package orm
+
+// CaseBuilder 用于构造 CASE 表达式，例如
+// Case().When(C("Age").LT(18), "child").When(C("Age").LT(60), "adult").Else("senior").End()
+type CaseBuilder struct {
+	whens []caseWhen
+	els   Expression
+}
+
+type caseWhen struct {
+	cond Predicate
+	val  Expression
+}
+
+// Case 开始构造一个 CASE 表达式
+func Case() CaseBuilder {
+	return CaseBuilder{}
+}
+
+// When 加入 WHEN cond THEN val，val 不是 Expression 的时候会作为参数值
+func (c CaseBuilder) When(cond Predicate, val any) CaseBuilder {
+	whens := make([]caseWhen, len(c.whens), len(c.whens)+1)
+	copy(whens, c.whens)
+	c.whens = append(whens, caseWhen{cond: cond, val: exprOf(val)})
+	return c
+}
+
+// Else 设置 ELSE 部分，不设置的话没有匹配的 WHEN 时结果是 NULL
+func (c CaseBuilder) Else(val any) CaseBuilder {
+	c.els = exprOf(val)
+	return c
+}
+
+// End 结束构造
+func (c CaseBuilder) End() CaseExpr {
+	return CaseExpr{
+		whens: c.whens,
+		els:   c.els,
+	}
+}
+
+// CaseExpr 代表 CASE WHEN ... THEN ... ELSE ... END，
+// 它可以用在 Select、Assign 和 OrderBy 里面
+type CaseExpr struct {
+	whens []caseWhen
+	els   Expression
+	alias string
+}
+
+func (c CaseExpr) expr() {}
+
+func (c CaseExpr) selectedAlias() string {
+	return c.alias
+}
+
+func (c CaseExpr) fieldName() string {
+	return ""
+}
+
+func (c CaseExpr) target() TableReference {
+	return nil
+}
+
+func (c CaseExpr) As(alias string) CaseExpr {
+	c.alias = alias
+	return c
+}
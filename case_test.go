@@ -0,0 +1,116 @@
+package orm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"orm/internal/errs"
+	"testing"
+)
+
+func TestCase_Build(t *testing.T) {
+	db := memoryDB(t)
+	bucket := Case().
+		When(C("Age").LT(18), "child").
+		When(C("Age").Between(18, 60), "adult").
+		Else("senior").End()
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "select",
+			q:    NewSelector[TestModel](db).Select(C("Id"), bucket.As("bucket")),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,CASE WHEN `age` < ? THEN ? WHEN `age` BETWEEN ? AND ? THEN ? " +
+					"ELSE ? END AS `bucket` FROM `test_model`;",
+				Args: []any{18, "child", 18, 60, "adult", "senior"},
+			},
+		},
+		{
+			name: "without else",
+			q: NewSelector[TestModel](db).
+				Select(Case().When(C("LastName").IsNull(), C("FirstName")).End()),
+			wantQuery: &Query{
+				SQL: "SELECT CASE WHEN `last_name` IS NULL THEN `first_name` END FROM `test_model`;",
+			},
+		},
+		{
+			name: "order by",
+			q: NewSelector[TestModel](db).Where(C("Id").GT(10)).
+				OrderBy(Asc(Case().When(C("FirstName").EQ("Tom"), 0).Else(1).End()), Asc("Id")),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `id` > ? " +
+					"ORDER BY CASE WHEN `first_name` = ? THEN ? ELSE ? END ASC,`id` ASC;",
+				Args: []any{10, "Tom", 0, 1},
+			},
+		},
+		{
+			name: "assign",
+			q: NewUpdater[TestModel](db).Set(
+				Assign("Age", Case().When(C("Age").LT(18), C("Age").Add(1)).Else(C("Age")).End()),
+			).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `age` = CASE WHEN `age` < ? THEN (`age` + ?) ELSE `age` END WHERE `id` = ?;",
+				Args: []any{18, 1, 1},
+			},
+		},
+		{
+			name:    "no when",
+			q:       NewSelector[TestModel](db).Select(Case().Else(1).End()),
+			wantErr: errs.ErrCaseWithoutWhen,
+		},
+		{
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).Select(Case().When(C("Invalid").EQ(1), 1).End()),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+// When 不会修改原本的 CaseBuilder
+func TestCaseBuilder_When(t *testing.T) {
+	base := Case().When(C("Age").LT(18), "child")
+	c1 := base.When(C("Age").LT(60), "adult").End()
+	c2 := base.When(C("Age").GT(60), "senior").End()
+	assert.Len(t, base.whens, 1)
+	assert.Equal(t, exprOf("adult"), c1.whens[1].val)
+	assert.Equal(t, exprOf("senior"), c2.whens[1].val)
+}
+
+func TestCase_Exec(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("case", t)
+	require.NoError(t, NewCreater[TestModel](db).Exec(ctx).Err())
+	for _, val := range []*TestModel{
+		{Id: 1, FirstName: "Tom", Age: 16},
+		{Id: 2, FirstName: "Jerry", Age: 30},
+	} {
+		require.NoError(t, NewInserter[TestModel](db).Values(val).Exec(ctx).Err())
+	}
+	// 未成年的年龄加一
+	require.NoError(t, NewUpdater[TestModel](db).Set(
+		Assign("Age", Case().When(C("Age").LT(18), C("Age").Add(1)).Else(C("Age")).End()),
+	).Exec(ctx).Err())
+
+	res, err := NewSelector[TestModel](db).
+		OrderBy(Asc(Case().When(C("FirstName").EQ("Jerry"), 0).Else(1).End())).
+		GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*TestModel{
+		{Id: 2, FirstName: "Jerry", Age: 30},
+		{Id: 1, FirstName: "Tom", Age: 17},
+	}, res)
+}
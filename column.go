@@ -61,6 +61,7 @@ func valueOf(val any) Expression {
 
 func (c Column) As(alias string) Column {
 	return Column{
+		table: c.table,
 		name:  c.name,
 		alias: alias,
 	}
@@ -91,10 +92,46 @@ func (c Column) GT(arg any) Predicate {
 	}
 }
 
-func (c Column) In(arg any) Predicate {
-	return Predicate{
-		left:  c,
-		op:    opIN,
-		right: exprOf(arg),
-	}
+// In 例如 C("Id").In(1, 2, 3) 或者 C("Id").In([]int{1, 2, 3})，
+// 也可以传入一个子查询 C("Id").In(sub)
+func (c Column) In(vals ...any) Predicate {
+	return newIn(c, opIN, vals)
+}
+
+func (c Column) NEQ(arg any) Predicate {
+	return newPredicate(c, opNEQ, arg)
+}
+
+func (c Column) LTE(arg any) Predicate {
+	return newPredicate(c, opLTE, arg)
+}
+
+func (c Column) GTE(arg any) Predicate {
+	return newPredicate(c, opGTE, arg)
+}
+
+// Like 例如 C("FirstName").Like("Tom%")
+func (c Column) Like(pattern any) Predicate {
+	return newPredicate(c, opLike, pattern)
+}
+
+func (c Column) NotLike(pattern any) Predicate {
+	return newPredicate(c, opNotLike, pattern)
+}
+
+// Between 例如 C("Age").Between(18, 35)，包含两端
+func (c Column) Between(lo, hi any) Predicate {
+	return newBetween(c, lo, hi)
+}
+
+func (c Column) NotIn(vals ...any) Predicate {
+	return newIn(c, opNotIN, vals)
+}
+
+func (c Column) IsNull() Predicate {
+	return newNullPredicate(c, opIsNull)
+}
+
+func (c Column) IsNotNull() Predicate {
+	return newNullPredicate(c, opIsNotNull)
 }
@@ -0,0 +1,148 @@
+package orm
+
+import (
+	"orm/internal/errs"
+	"orm/model"
+	"reflect"
+)
+
+// Pair 用于接收两张表 JOIN 的结果，例如
+// NewSelector[Pair[User, Order]](db).From(u.Join(o).On(...))。
+// First 和 Second 按照类型匹配 FROM 里面的表，
+// 自连接的时候按照表在 FROM 里面出现的顺序匹配。
+// 注意 LEFT JOIN 和 RIGHT JOIN 可能返回 NULL，对应的字段需要使用指针或者 sql.NullXXX。
+//
+// 自己定义组合结构体的时候，每一部分都要带上 alias 标签，例如 UserOrder{User `orm:"alias"`; Order `orm:"alias"`}，
+// alias 没有值的时候按照类型匹配。直接嵌入 User 和 Order 会把它们的字段展开到同一张表里面，
+// 同名的字段，例如 Id，会返回 errs.NewErrAmbiguousField
+type Pair[A any, B any] struct {
+	First  A `orm:"alias"`
+	Second B `orm:"alias"`
+}
+
+// joinPart 组合结构体的一部分，以及它在 SQL 里面使用的限定名，
+// 也就是表的别名，没有别名的时候就是表名
+type joinPart struct {
+	*model.Part
+	qualifier string
+}
+
+// resolveParts 为组合结构体的每一部分找到 FROM 里面对应的表，
+// 并且构造用于接收结果的元数据，里面的列名都是 限定名.列名 的形式，
+// 这样即便两张表有同名的列也能区分开
+func (s *Selector[T]) resolveParts() error {
+	if s.scanModel != nil || len(s.model.Parts) == 0 {
+		return nil
+	}
+	tables := joinedTables(s.table, nil)
+	used := make([]bool, len(tables))
+	parts := make([]joinPart, 0, len(s.model.Parts))
+	fields := make([]*model.Field, 0, 16)
+	fds := make(map[string]*model.Field, 16)
+	colMap := make(map[string]*model.Field, 16)
+	for _, part := range s.model.Parts {
+		idx := -1
+		for i, tbl := range tables {
+			if used[i] {
+				continue
+			}
+			if part.Alias != "" {
+				if tbl.alias == part.Alias {
+					idx = i
+					break
+				}
+				continue
+			}
+			if reflect.TypeOf(tbl.entity) == reflect.PointerTo(part.Model.Type) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return errs.NewErrNoTableForPart(part.GoName)
+		}
+		used[idx] = true
+		qualifier := tables[idx].alias
+		if qualifier == "" {
+			m, err := s.r.Get(tables[idx].entity)
+			if err != nil {
+				return err
+			}
+			qualifier = m.TableName
+		}
+		parts = append(parts, joinPart{Part: part, qualifier: qualifier})
+
+		for _, fd := range part.Model.Fields {
+			f := *fd
+			f.ColName = qualifier + "." + fd.ColName
+			f.GoName = part.GoName + "." + fd.GoName
+			f.Offset = part.Offset + fd.Offset
+			f.Index = make([]int, 0, len(part.Index)+len(fd.Index))
+			f.Index = append(append(f.Index, part.Index...), fd.Index...)
+			fields = append(fields, &f)
+			fds[f.GoName] = &f
+			colMap[f.ColName] = &f
+		}
+	}
+	s.parts = parts
+	s.scanModel = &model.Model{
+		TableName: s.model.TableName,
+		Type:      s.model.Type,
+		Fields:    fields,
+		FieldMap:  fds,
+		ColumnMap: colMap,
+	}
+	return nil
+}
+
+// buildPartColumns 没有指定列的时候，选择每一部分的所有列，并且使用 限定名.列名 作为别名
+func (s *Selector[T]) buildPartColumns() {
+	first := true
+	for _, part := range s.parts {
+		for _, fd := range part.Model.Fields {
+			if !first {
+				s.writeComma()
+			}
+			first = false
+			s.quote(part.qualifier)
+			s.writeByte('.')
+			s.quote(fd.ColName)
+			s.writeString(" AS ")
+			s.quote(part.qualifier + "." + fd.ColName)
+		}
+	}
+}
+
+// partColumnLabel 返回组合结构体用于接收该列的别名，
+// 只有指定了表并且没有指定别名的列才需要
+func (s *Selector[T]) partColumnLabel(c Column) (string, error) {
+	tbl, ok := c.table.(Table)
+	if !ok || c.alias != "" {
+		return "", nil
+	}
+	m, err := s.r.Get(tbl.entity)
+	if err != nil {
+		return "", err
+	}
+	fd, ok := m.FieldMap[c.name]
+	if !ok {
+		return "", errs.NewErrUnknownField(c.name)
+	}
+	qualifier := tbl.alias
+	if qualifier == "" {
+		qualifier = m.TableName
+	}
+	return qualifier + "." + fd.ColName, nil
+}
+
+// joinedTables 按照出现的顺序返回 FROM 里面的表
+func joinedTables(table TableReference, res []Table) []Table {
+	switch tab := table.(type) {
+	case Table:
+		res = append(res, tab)
+	case Join:
+		res = joinedTables(tab.left, res)
+		res = joinedTables(tab.right, res)
+	}
+	return res
+}
@@ -0,0 +1,195 @@
+package orm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"orm/internal/errs"
+	"orm/internal/valuer"
+	"testing"
+)
+
+type JoinUser struct {
+	Id   int64 `orm:"pk"`
+	Name string
+}
+
+type JoinOrder struct {
+	Id     int64 `orm:"pk"`
+	UserId int64
+	Amount int
+}
+
+// UserOrder 通过别名匹配 FROM 里面的表
+type UserOrder struct {
+	JoinUser  `orm:"alias=u"`
+	JoinOrder `orm:"alias=o"`
+	Remark    string `orm:"-"`
+}
+
+func TestSelector_Composite(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "pair",
+			q: func() QueryBuilder {
+				u := TableOf(&JoinUser{}).As("u")
+				o := TableOf(&JoinOrder{}).As("o")
+				return NewSelector[Pair[JoinUser, JoinOrder]](db).
+					From(u.Join(o).On(u.C("Id").EQ(o.C("UserId"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `u`.`id` AS `u.id`,`u`.`name` AS `u.name`," +
+					"`o`.`id` AS `o.id`,`o`.`user_id` AS `o.user_id`,`o`.`amount` AS `o.amount` " +
+					"FROM (`join_user` AS `u` JOIN `join_order` AS `o` ON `u`.`id` = `o`.`user_id`);",
+			},
+		},
+		{
+			// 类型和 FROM 里面的顺序无关
+			name: "pair without alias",
+			q: func() QueryBuilder {
+				u := TableOf(&JoinUser{})
+				o := TableOf(&JoinOrder{})
+				return NewSelector[Pair[JoinOrder, JoinUser]](db).
+					From(u.Join(o).On(u.C("Id").EQ(o.C("UserId"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `join_order`.`id` AS `join_order.id`,`join_order`.`user_id` AS `join_order.user_id`," +
+					"`join_order`.`amount` AS `join_order.amount`," +
+					"`join_user`.`id` AS `join_user.id`,`join_user`.`name` AS `join_user.name` " +
+					"FROM (`join_user` JOIN `join_order` ON `join_user`.`id` = `join_order`.`user_id`);",
+			},
+		},
+		{
+			name: "self join",
+			q: func() QueryBuilder {
+				u1 := TableOf(&JoinUser{}).As("u1")
+				u2 := TableOf(&JoinUser{}).As("u2")
+				return NewSelector[Pair[JoinUser, JoinUser]](db).
+					From(u1.Join(u2).On(u1.C("Name").EQ(u2.C("Name"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `u1`.`id` AS `u1.id`,`u1`.`name` AS `u1.name`," +
+					"`u2`.`id` AS `u2.id`,`u2`.`name` AS `u2.name` " +
+					"FROM (`join_user` AS `u1` JOIN `join_user` AS `u2` ON `u1`.`name` = `u2`.`name`);",
+			},
+		},
+		{
+			name: "specify columns",
+			q: func() QueryBuilder {
+				u := TableOf(&JoinUser{}).As("u")
+				o := TableOf(&JoinOrder{}).As("o")
+				return NewSelector[UserOrder](db).
+					Select(u.C("Name"), o.C("Amount"), o.C("Id").As("o.id")).
+					From(u.Join(o).On(u.C("Id").EQ(o.C("UserId"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `u`.`name` AS `u.name`,`o`.`amount` AS `o.amount`,`o`.`id` AS `o.id` " +
+					"FROM (`join_user` AS `u` JOIN `join_order` AS `o` ON `u`.`id` = `o`.`user_id`);",
+			},
+		},
+		{
+			// 没有 alias 标签的嵌入结构体会被展开，两边的 Id 有歧义
+			name: "embedded without alias",
+			q: func() QueryBuilder {
+				type PlainUserOrder struct {
+					JoinUser
+					JoinOrder
+				}
+				u := TableOf(&JoinUser{})
+				o := TableOf(&JoinOrder{})
+				return NewSelector[PlainUserOrder](db).From(u.Join(o).On(u.C("Id").EQ(o.C("UserId"))))
+			}(),
+			wantErr: errs.NewErrAmbiguousField("Id"),
+		},
+		{
+			// alias 没有值的时候按照类型匹配
+			name: "embedded with empty alias",
+			q: func() QueryBuilder {
+				type TypedUserOrder struct {
+					JoinUser  `orm:"alias"`
+					JoinOrder `orm:"alias"`
+				}
+				u := TableOf(&JoinUser{})
+				o := TableOf(&JoinOrder{})
+				return NewSelector[TypedUserOrder](db).From(u.Join(o).On(u.C("Id").EQ(o.C("UserId"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `join_user`.`id` AS `join_user.id`,`join_user`.`name` AS `join_user.name`," +
+					"`join_order`.`id` AS `join_order.id`,`join_order`.`user_id` AS `join_order.user_id`," +
+					"`join_order`.`amount` AS `join_order.amount` " +
+					"FROM (`join_user` JOIN `join_order` ON `join_user`.`id` = `join_order`.`user_id`);",
+			},
+		},
+		{
+			name: "no table",
+			q: func() QueryBuilder {
+				u := TableOf(&JoinUser{}).As("u")
+				return NewSelector[UserOrder](db).From(u)
+			}(),
+			wantErr: errs.NewErrNoTableForPart("JoinOrder"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSelector_CompositeGetMulti(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("composite", t)
+	require.NoError(t, NewCreater[JoinUser](db).Exec(ctx).Err())
+	require.NoError(t, NewCreater[JoinOrder](db).Exec(ctx).Err())
+	for _, s := range []string{
+		"INSERT INTO `join_user`(`id`,`name`) VALUES (1,'Tom'),(2,'Jerry');",
+		"INSERT INTO `join_order`(`id`,`user_id`,`amount`) VALUES (10,1,100),(11,1,200),(12,2,300);",
+	} {
+		require.NoError(t, RawQuery[any](db, s).Exec(ctx).Err())
+	}
+	u := TableOf(&JoinUser{}).As("u")
+	o := TableOf(&JoinOrder{}).As("o")
+	from := u.Join(o).On(u.C("Id").EQ(o.C("UserId")))
+
+	for _, c := range []struct {
+		name string
+		db   *DB
+	}{
+		{name: "reflect", db: db},
+		{name: "unsafe", db: func() *DB {
+			db, err := Open("sqlite3", "file:composite.db?cache=shared&mode=memory",
+				DBWithValCreator(valuer.NewUnsafeValue))
+			require.NoError(t, err)
+			return db
+		}()},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			pairs, err := NewSelector[Pair[JoinUser, JoinOrder]](c.db).From(from).
+				Where(u.C("Id").EQ(1)).GetMulti(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, []*Pair[JoinUser, JoinOrder]{
+				{First: JoinUser{Id: 1, Name: "Tom"}, Second: JoinOrder{Id: 10, UserId: 1, Amount: 100}},
+				{First: JoinUser{Id: 1, Name: "Tom"}, Second: JoinOrder{Id: 11, UserId: 1, Amount: 200}},
+			}, pairs)
+
+			res, err := NewSelector[UserOrder](c.db).From(from).
+				Where(o.C("Id").EQ(12)).Get(ctx)
+			require.NoError(t, err)
+			assert.Equal(t, &UserOrder{
+				JoinUser:  JoinUser{Id: 2, Name: "Jerry"},
+				JoinOrder: JoinOrder{Id: 12, UserId: 2, Amount: 300},
+			}, res)
+		})
+	}
+}
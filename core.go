@@ -14,6 +14,8 @@ type core struct {
 	dialect    Dialect
 	ms         []Middleware
 	valCreator valuer.BasicTypeCreator
+	// safeInsertID 批量插入的时候不推断自增主键，见 DBWithSafeInsertID
+	safeInsertID bool
 }
 
 func getMultiHandler[T any](ctx context.Context, c core,
@@ -109,15 +111,19 @@ func get[T any](ctx context.Context, c core,
 
 func exec[T any](ctx context.Context, c core,
 	sess session, qc *QueryContext) Result {
-	var handler HandleFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
+	return execWithHandler(ctx, c, qc, func(ctx context.Context, qc *QueryContext) *QueryResult {
 		q, err := qc.Query()
 		if err != nil {
 			return &QueryResult{Err: err}
 		}
 		res, err := sess.execContext(ctx, q.SQL, q.Args...)
 		return &QueryResult{Result: res, Err: err}
-	}
+	})
+}
 
+// execWithHandler 以 handler 作为最里层，经过所有的 Middleware 执行 qc。
+// handler 返回的 Result 必须是 sql.Result
+func execWithHandler(ctx context.Context, c core, qc *QueryContext, handler HandleFunc) Result {
 	ms := c.ms
 	for i := len(ms) - 1; i >= 0; i-- {
 		handler = ms[i](handler)
@@ -1,47 +1,276 @@
 package orm
 
-// Creater builds CREATE Query
+import (
+	"context"
+	"database/sql/driver"
+	"github.com/valyala/bytebufferpool"
+	"orm/model"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Creater 用于构造 CREATE TABLE 语句，
+// 列类型来自 Dialect.ColTypeOf，约束和索引来自 orm 标签
 type Creater[T any] struct {
+	tableCreater
+	sess session
+}
+
+func NewCreater[T any](sess session) *Creater[T] {
+	c := sess.getCore()
+	return &Creater[T]{
+		sess: sess,
+		tableCreater: tableCreater{
+			Builder: Builder{
+				core:     c,
+				buffer:   bytebufferpool.Get(),
+				aliasMap: make(map[string]int, 8),
+			},
+		},
+	}
+}
+
+// IfNotExists 生成 CREATE TABLE IF NOT EXISTS，索引也会加上 IF NOT EXISTS。
+// 注意 SQL Server 不支持这种语法
+func (c *Creater[T]) IfNotExists() *Creater[T] {
+	c.ifNotExists = true
+	return c
+}
+
+// Build 构造 CREATE TABLE 语句。
+// 唯一索引总是作为约束定义在表里面，
+// 普通索引只有在 Dialect.SupportInlineIndex 的时候才会定义在表里面，
+// 否则需要通过 BuildIndexes 获得单独的 CREATE INDEX 语句
+func (c *Creater[T]) Build() (*Query, error) {
+	if err := c.initModel(); err != nil {
+		c.release()
+		return nil, err
+	}
+	return c.tableCreater.Build()
+}
+
+// BuildIndexes 构造不能定义在 CREATE TABLE 里面的索引
+func (c *Creater[T]) BuildIndexes() ([]*Query, error) {
+	if err := c.initModel(); err != nil {
+		return nil, err
+	}
+	creaters := c.indexCreaters()
+	res := make([]*Query, 0, len(creaters))
+	for _, ic := range creaters {
+		q, err := ic.Build()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, q)
+	}
+	return res, nil
+}
+
+// Exec 执行 CREATE TABLE，以及后续的 CREATE INDEX
+// 每一条语句都会单独经过 Middleware
+func (c *Creater[T]) Exec(ctx context.Context) Result {
+	if err := c.initModel(); err != nil {
+		return Result{err: err}
+	}
+	creaters := c.indexCreaters()
+	res := exec[T](ctx, c.core, c.sess, &QueryContext{
+		Type:    "CREATE",
+		Builder: c,
+		Meta:    c.model,
+	})
+	for _, ic := range creaters {
+		if res.Err() != nil {
+			return res
+		}
+		res = exec[T](ctx, c.core, c.sess, &QueryContext{
+			Type:    "CREATE",
+			Builder: ic,
+			Meta:    c.model,
+		})
+	}
+	return res
+}
+
+func (c *Creater[T]) initModel() error {
+	if c.model != nil {
+		return nil
+	}
+	m, err := c.r.Get(new(T))
+	if err != nil {
+		return err
+	}
+	c.model = m
+	return nil
+}
+
+// tableCreater 根据 model 构造 CREATE TABLE 语句，
+// 使用之前必须设置好 model
+type tableCreater struct {
+	Builder
+	ifNotExists bool
+}
+
+func newTableCreater(c core, m *model.Model, ifNotExists bool) *tableCreater {
+	return &tableCreater{
+		Builder: Builder{
+			core:   c,
+			buffer: bytebufferpool.Get(),
+			model:  m,
+		},
+		ifNotExists: ifNotExists,
+	}
+}
+
+func (c *tableCreater) Build() (*Query, error) {
+	defer c.release()
+	c.writeString("CREATE TABLE ")
+	if c.ifNotExists {
+		c.writeString("IF NOT EXISTS ")
+	}
+	c.quote(c.model.TableName)
+	c.writeString(" (")
+	for i, fd := range c.model.Fields {
+		if i > 0 {
+			c.writeComma()
+		}
+		c.buildColumnDefinition(fd)
+	}
+	if len(c.model.PrimaryKeys) > 0 {
+		c.writeString(",PRIMARY KEY")
+		c.buildFieldList(c.model.PrimaryKeys)
+	}
+	for _, idx := range c.model.Indexes {
+		switch {
+		case idx.Unique:
+			c.writeString(",CONSTRAINT ")
+			c.quote(indexName(c.model.TableName, idx))
+			c.writeString(" UNIQUE")
+			c.buildFieldList(idx.Fields)
+		case c.dialect.SupportInlineIndex():
+			c.writeString(",INDEX ")
+			c.quote(indexName(c.model.TableName, idx))
+			c.buildFieldList(idx.Fields)
+		}
+	}
+	c.writeRightParenthesis()
+	c.end()
+	return &Query{
+		SQL:  c.buffer.String(),
+		Args: c.args,
+	}, nil
+}
+
+// indexCreaters 返回不能定义在 CREATE TABLE 里面的索引
+func (c *tableCreater) indexCreaters() []*indexCreater {
+	if c.dialect.SupportInlineIndex() {
+		return nil
+	}
+	res := make([]*indexCreater, 0, len(c.model.Indexes))
+	for _, idx := range c.model.Indexes {
+		if idx.Unique {
+			continue
+		}
+		res = append(res, newIndexCreater(c.core, c.model, idx, c.ifNotExists))
+	}
+	return res
+}
+
+// buildColumnDefinition 构造 `col` type [NOT NULL] [DEFAULT val] 部分
+func (b *Builder) buildColumnDefinition(fd *model.Field) {
+	b.quote(fd.ColName)
+	b.writeSpace()
+	colType := fd.SQLType
+	if colType == "" {
+		typ := columnType(fd.Type)
+		if fd.Size > 0 && typ.Kind() == reflect.String {
+			colType = "varchar(" + strconv.Itoa(fd.Size) + ")"
+		} else {
+			colType = b.dialect.ColTypeOf(reflect.New(typ).Elem())
+		}
+	}
+	if fd.AutoIncrement {
+		colType = b.dialect.AutoIncrement(colType)
+	}
+	b.writeString(colType)
+	if !fd.Nullable {
+		b.writeString(" NOT NULL")
+	}
+	if fd.Default != "" {
+		b.writeString(" DEFAULT ")
+		b.writeString(fd.Default)
+	}
+}
+
+// indexCreater 构造 CREATE INDEX 语句
+type indexCreater struct {
 	Builder
-	db *DB
-}
-
-// Build returns CREATE Query
-//func (c *Creater[T]) Build() (*Query, error) {
-//	defer bytebufferpool.Put(c.buffer)
-//	var (
-//		t   T
-//		err error
-//	)
-//	c.writeString("CREATE TABLE ")
-//	c.model, err = c.db.r.Get(&t)
-//	if err != nil {
-//		return nil, err
-//	}
-//	c.quote(c.model.TableName)
-//	c.writeString(" (")
-//	for colName, fdMeta := range c.model.ColumnMap {
-//		c.quote(colName)
-//		c.space()
-//		c.writeString(fdMeta.ColumnTyp)
-//		if fdMeta.IsNull {
-//			c.space()
-//			c.writeString("NULL")
-//		} else {
-//			c.space()
-//			c.writeString("NOT NULL")
-//		}
-//		if fdMeta.IsAutoIncrement {
-//			c.space()
-//			c.writeString("AUTO_INCREMENT")
-//		}
-//		if fdMeta.IsPrimaryKey {
-//			c.space()
-//			c.writeString("AUTO_INCREMENT")
-//		}
-//		c.comma()
-//	}
-//	c.writeString(" )")
-//	c.end()
-//	return &Query{SQL: c.buffer.String()}, nil
-//}
+	index       *model.Index
+	ifNotExists bool
+}
+
+func newIndexCreater(c core, m *model.Model, idx *model.Index, ifNotExists bool) *indexCreater {
+	return &indexCreater{
+		Builder: Builder{
+			core:   c,
+			buffer: bytebufferpool.Get(),
+			model:  m,
+		},
+		index:       idx,
+		ifNotExists: ifNotExists,
+	}
+}
+
+func (i *indexCreater) Build() (*Query, error) {
+	defer i.release()
+	i.writeString("CREATE ")
+	if i.index.Unique {
+		i.writeString("UNIQUE ")
+	}
+	i.writeString("INDEX ")
+	if i.ifNotExists {
+		i.writeString("IF NOT EXISTS ")
+	}
+	i.quote(indexName(i.model.TableName, i.index))
+	i.writeString(" ON ")
+	i.quote(i.model.TableName)
+	i.buildFieldList(i.index.Fields)
+	i.end()
+	return &Query{SQL: i.buffer.String()}, nil
+}
+
+// indexName 用户没有指定索引名的时候，
+// 普通索引使用 idx_表名_列名，唯一索引使用 uk_表名_列名
+func indexName(table string, idx *model.Index) string {
+	if idx.Name != "" {
+		return idx.Name
+	}
+	var sb strings.Builder
+	if idx.Unique {
+		sb.WriteString("uk_")
+	} else {
+		sb.WriteString("idx_")
+	}
+	sb.WriteString(table)
+	for _, fd := range idx.Fields {
+		sb.WriteByte('_')
+		sb.WriteString(fd.ColName)
+	}
+	return sb.String()
+}
+
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+
+// columnType 返回用于推断列类型的 Go 类型。
+// 指针会被解引用，类似于 sql.NullString 这种实现了 driver.Valuer 的结构体，
+// 使用它的第一个字段，也就是真正的值
+func columnType(typ reflect.Type) reflect.Type {
+	for typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ.Kind() == reflect.Struct && typ.NumField() > 0 &&
+		(typ.Implements(valuerType) || reflect.PointerTo(typ).Implements(valuerType)) {
+		return typ.Field(0).Type
+	}
+	return typ
+}
@@ -0,0 +1,118 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+type CreateTestModel struct {
+	Id        int64  `orm:"primary_key,auto_increment"`
+	Name      string `orm:"unique,size=64"`
+	Age       *int8  `orm:"index"`
+	Nickname  sql.NullString
+	Address   string `orm:"type=varchar(128),default=''"`
+	CreatedAt time.Time
+}
+
+func TestCreater_Build(t *testing.T) {
+	testCases := []struct {
+		name        string
+		db          *DB
+		ifNotExists bool
+		wantQuery   *Query
+		wantIndexes []*Query
+	}{
+		{
+			name: "sqlite",
+			db:   memoryDB(t),
+			wantQuery: &Query{
+				SQL: "CREATE TABLE `create_test_model` (`id` integer NOT NULL,`name` varchar(64) NOT NULL," +
+					"`age` integer,`nickname` text,`address` varchar(128) NOT NULL DEFAULT ''," +
+					"`created_at` datetime NOT NULL,PRIMARY KEY(`id`)," +
+					"CONSTRAINT `uk_create_test_model_name` UNIQUE(`name`));",
+			},
+			wantIndexes: []*Query{
+				{SQL: "CREATE INDEX `idx_create_test_model_age` ON `create_test_model`(`age`);"},
+			},
+		},
+		{
+			name:        "sqlite if not exists",
+			db:          memoryDB(t),
+			ifNotExists: true,
+			wantQuery: &Query{
+				SQL: "CREATE TABLE IF NOT EXISTS `create_test_model` (`id` integer NOT NULL,`name` varchar(64) NOT NULL," +
+					"`age` integer,`nickname` text,`address` varchar(128) NOT NULL DEFAULT ''," +
+					"`created_at` datetime NOT NULL,PRIMARY KEY(`id`)," +
+					"CONSTRAINT `uk_create_test_model_name` UNIQUE(`name`));",
+			},
+			wantIndexes: []*Query{
+				{SQL: "CREATE INDEX IF NOT EXISTS `idx_create_test_model_age` ON `create_test_model`(`age`);"},
+			},
+		},
+		{
+			name: "mysql",
+			db:   memoryDB(t, DBWithDialect(MySQL)),
+			wantQuery: &Query{
+				SQL: "CREATE TABLE `create_test_model` (`id` bigint(11) AUTO_INCREMENT NOT NULL,`name` varchar(64) NOT NULL," +
+					"`age` int(11),`nickname` longtext,`address` varchar(128) NOT NULL DEFAULT ''," +
+					"`created_at` datetime NOT NULL,PRIMARY KEY(`id`)," +
+					"CONSTRAINT `uk_create_test_model_name` UNIQUE(`name`)," +
+					"INDEX `idx_create_test_model_age`(`age`));",
+			},
+			wantIndexes: []*Query{},
+		},
+		{
+			name: "postgres",
+			db:   memoryDB(t, DBWithDialect(Postgres)),
+			wantQuery: &Query{
+				SQL: `CREATE TABLE "create_test_model" ("id" bigint GENERATED BY DEFAULT AS IDENTITY NOT NULL,"name" varchar(64) NOT NULL,` +
+					`"age" smallint,"nickname" text,"address" varchar(128) NOT NULL DEFAULT '',` +
+					`"created_at" timestamp NOT NULL,PRIMARY KEY("id"),` +
+					`CONSTRAINT "uk_create_test_model_name" UNIQUE("name"));`,
+			},
+			wantIndexes: []*Query{
+				{SQL: `CREATE INDEX "idx_create_test_model_age" ON "create_test_model"("age");`},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := NewCreater[CreateTestModel](tc.db)
+			if tc.ifNotExists {
+				c = c.IfNotExists()
+			}
+			q, err := c.Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, q)
+			indexes, err := c.BuildIndexes()
+			require.NoError(t, err)
+			assert.ElementsMatch(t, tc.wantIndexes, indexes)
+		})
+	}
+}
+
+func TestCreater_Exec(t *testing.T) {
+	db := memoryDBWithDB("create", t)
+	ctx := context.Background()
+	res := NewCreater[CreateTestModel](db).Exec(ctx)
+	require.NoError(t, res.Err())
+	// 再次执行会因为表已经存在而失败
+	res = NewCreater[CreateTestModel](db).Exec(ctx)
+	assert.Error(t, res.Err())
+	res = NewCreater[CreateTestModel](db).IfNotExists().Exec(ctx)
+	require.NoError(t, res.Err())
+
+	res = NewInserter[CreateTestModel](db).Values(&CreateTestModel{
+		Id: 1, Name: "Tom", CreatedAt: time.Now(),
+	}).Exec(ctx)
+	require.NoError(t, res.Err())
+	res = NewInserter[CreateTestModel](db).Values(&CreateTestModel{
+		Id: 2, Name: "Tom", CreatedAt: time.Now(),
+	}).Exec(ctx)
+	// 违反唯一约束
+	assert.Error(t, res.Err())
+}
@@ -0,0 +1,160 @@
+package orm
+
+import "orm/internal/errs"
+
+// commonTable 是 WITH 里面定义的一个公用表表达式 name AS (q)
+type commonTable struct {
+	name      string
+	q         QueryBuilder
+	recursive bool
+}
+
+// CommonTable 引用 WITH 定义的公用表表达式，它可以像 Table 一样用在 From 和 Join 里面，例如
+// NewSelector[Category](db).With("tree", q).From(CTE("tree"))。
+// 它的列和 Subquery 一样，通过定义它的查询来解析
+type CommonTable struct {
+	name  string
+	alias string
+}
+
+// CTE 引用名字为 name 的公用表表达式
+func CTE(name string) CommonTable {
+	return CommonTable{name: name}
+}
+
+func (c CommonTable) expr() {}
+
+// tableAlias 没有别名的时候使用 CTE 的名字来限定列
+func (c CommonTable) tableAlias() string {
+	if c.alias != "" {
+		return c.alias
+	}
+	return c.name
+}
+
+func (c CommonTable) As(alias string) CommonTable {
+	return CommonTable{
+		name:  c.name,
+		alias: alias,
+	}
+}
+
+func (c CommonTable) C(name string) Column {
+	return Column{table: c, name: name}
+}
+
+func (c CommonTable) Join(target TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  c,
+		right: target,
+		typ:   "JOIN",
+	}
+}
+
+func (c CommonTable) LeftJoin(target TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  c,
+		right: target,
+		typ:   "LEFT JOIN",
+	}
+}
+
+func (c CommonTable) RightJoin(target TableReference) *JoinBuilder {
+	return &JoinBuilder{
+		left:  c,
+		right: target,
+		typ:   "RIGHT JOIN",
+	}
+}
+
+// cteSetter 内嵌了 Builder 的 QueryBuilder 都实现了该接口，
+// 用于让子查询和 CTE 的定义里面也能引用外部定义的 CTE
+type cteSetter interface {
+	setCTEs(ctes map[string]Subquery)
+}
+
+func (b *Builder) setCTEs(ctes map[string]Subquery) {
+	b.ctes = ctes
+}
+
+// passCTEs 将当前可见的 CTE 传递给 q
+func (b *Builder) passCTEs(q QueryBuilder) {
+	if setter, ok := q.(cteSetter); ok && len(b.ctes) > 0 {
+		setter.setCTEs(b.ctes)
+	}
+}
+
+// with 加入一个 CTE
+func (b *Builder) with(name string, q QueryBuilder, recursive bool) {
+	b.withs = append(b.withs, commonTable{name: name, q: q, recursive: recursive})
+}
+
+// buildWith 构造 WITH [RECURSIVE] name AS (...),name AS (...) 部分，末尾带一个空格。
+// 只要有一个 CTE 是递归的，就需要 RECURSIVE 关键字。
+// 注意 SQL Server 不支持 RECURSIVE 关键字
+func (b *Builder) buildWith() error {
+	if len(b.withs) == 0 {
+		return nil
+	}
+	// 复制一份，避免内部定义的 CTE 泄露到外部
+	ctes := make(map[string]Subquery, len(b.ctes)+len(b.withs))
+	for name, sub := range b.ctes {
+		ctes[name] = sub
+	}
+	b.ctes = ctes
+	b.writeString("WITH ")
+	for _, ct := range b.withs {
+		if ct.recursive {
+			b.writeString("RECURSIVE ")
+			break
+		}
+	}
+	for i, ct := range b.withs {
+		if i > 0 {
+			b.writeComma()
+		}
+		// 先注册再构造，这样递归的 CTE 可以引用自己
+		ctes[ct.name] = cteSubquery(ct.name, ct.q)
+		b.quote(ct.name)
+		b.writeString(" AS ")
+		if setter, ok := ct.q.(argsOffsetSetter); ok {
+			setter.setArgsOffset(b.argsOffset + len(b.args))
+		}
+		b.passCTEs(ct.q)
+		q, err := ct.q.Build()
+		if err != nil {
+			return err
+		}
+		b.writeLeftParenthesis()
+		b.writeString(trimEnd(q.SQL))
+		b.writeRightParenthesis()
+		if len(q.Args) > 0 {
+			b.addArgs(q.Args...)
+		}
+	}
+	b.writeSpace()
+	return nil
+}
+
+// cteSubquery 返回用于解析 CTE 列名的 Subquery。
+// 对于 UNION 来说，列由第一个查询决定
+func cteSubquery(name string, q QueryBuilder) Subquery {
+	switch v := q.(type) {
+	case interface{ leftOperand() QueryBuilder }:
+		sub := cteSubquery(name, v.leftOperand())
+		sub.s = q
+		return sub
+	case interface{ AsSubquery(alias string) Subquery }:
+		return v.AsSubquery(name)
+	default:
+		return Subquery{s: q, alias: name}
+	}
+}
+
+func (b *Builder) cteOf(c CommonTable) (Subquery, error) {
+	sub, ok := b.ctes[c.name]
+	if !ok {
+		return Subquery{}, errs.NewErrUnknownCTE(c.name)
+	}
+	return sub, nil
+}
@@ -0,0 +1,169 @@
+package orm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"orm/internal/errs"
+	"testing"
+)
+
+type TreeCategory struct {
+	Id       int64 `orm:"pk"`
+	ParentId int64
+	Name     string
+}
+
+// categoryTree 查找 root 以及它所有的子孙分类
+func categoryTree(db *DB, root int64) QueryBuilder {
+	c := TableOf(&TreeCategory{}).As("c")
+	tree := CTE("tree")
+	anchor := NewSelector[TreeCategory](db).
+		Select(C("Id"), C("ParentId"), C("Name")).Where(C("Id").EQ(root))
+	recursive := NewSelector[TreeCategory](db).
+		Select(c.C("Id"), c.C("ParentId"), c.C("Name")).
+		From(c.Join(tree).On(c.C("ParentId").EQ(tree.C("Id"))))
+	return anchor.UnionAll(recursive)
+}
+
+func TestCTE_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "with",
+			q: func() QueryBuilder {
+				cheap := NewSelector[TreeCategory](db).Select(C("Id"), C("Name")).
+					Where(C("ParentId").EQ(1))
+				tbl := CTE("cheap")
+				return NewSelector[TreeCategory](db).With("cheap", cheap).
+					Select(tbl.C("Name")).From(tbl).Where(tbl.C("Id").GT(10))
+			}(),
+			wantQuery: &Query{
+				SQL: "WITH `cheap` AS (SELECT `id`,`name` FROM `tree_category` WHERE `parent_id` = ?) " +
+					"SELECT `cheap`.`name` FROM `cheap` WHERE `cheap`.`id` > ?;",
+				Args: []any{1, 10},
+			},
+		},
+		{
+			name: "recursive",
+			q: NewSelector[TreeCategory](db).WithRecursive("tree", categoryTree(db, 1)).
+				From(CTE("tree")),
+			wantQuery: &Query{
+				SQL: "WITH RECURSIVE `tree` AS (SELECT `id`,`parent_id`,`name` FROM `tree_category` WHERE `id` = ? " +
+					"UNION ALL SELECT `c`.`id`,`c`.`parent_id`,`c`.`name` FROM (`tree_category` AS `c` " +
+					"JOIN `tree` ON `c`.`parent_id` = `tree`.`id`)) SELECT * FROM `tree`;",
+				Args: []any{int64(1)},
+			},
+		},
+		{
+			name: "multiple with alias and join",
+			q: func() QueryBuilder {
+				a := NewSelector[TreeCategory](db).Where(C("ParentId").EQ(0))
+				b := NewSelector[TreeCategory](db).Where(C("ParentId").GT(0))
+				ta := CTE("a").As("ta")
+				tb := CTE("b")
+				return NewSelector[TreeCategory](db).With("a", a).With("b", b).
+					Select(tb.C("Id"), tb.C("Name")).
+					From(ta.Join(tb).On(tb.C("ParentId").EQ(ta.C("Id"))))
+			}(),
+			wantQuery: &Query{
+				SQL: "WITH `a` AS (SELECT * FROM `tree_category` WHERE `parent_id` = ?)," +
+					"`b` AS (SELECT * FROM `tree_category` WHERE `parent_id` > ?) " +
+					"SELECT `b`.`id`,`b`.`name` FROM (`a` AS `ta` JOIN `b` ON `b`.`parent_id` = `ta`.`id`);",
+				Args: []any{0, 0},
+			},
+		},
+		{
+			name: "delete",
+			q: NewDeleter[TreeCategory](db).WithRecursive("tree", categoryTree(db, 2)).
+				Where(C("Id").In(NewSelector[TreeCategory](db).Select(CTE("tree").C("Id")).
+					From(CTE("tree")).AsSubquery("sub"))),
+			wantQuery: &Query{
+				SQL: "WITH RECURSIVE `tree` AS (SELECT `id`,`parent_id`,`name` FROM `tree_category` WHERE `id` = ? " +
+					"UNION ALL SELECT `c`.`id`,`c`.`parent_id`,`c`.`name` FROM (`tree_category` AS `c` " +
+					"JOIN `tree` ON `c`.`parent_id` = `tree`.`id`)) " +
+					"DELETE FROM `tree_category` WHERE `id` IN (SELECT `tree`.`id` FROM `tree`);",
+				Args: []any{int64(2)},
+			},
+		},
+		{
+			name: "update",
+			q: NewUpdater[TreeCategory](db).
+				With("root", NewSelector[TreeCategory](db).Select(C("Id")).Where(C("ParentId").EQ(0))).
+				Set(Assign("Name", "top")).
+				Where(C("Id").In(NewSelector[TreeCategory](db).From(CTE("root")).AsSubquery("sub"))),
+			wantQuery: &Query{
+				SQL: "WITH `root` AS (SELECT `id` FROM `tree_category` WHERE `parent_id` = ?) " +
+					"UPDATE `tree_category` SET `name` = ? WHERE `id` IN (SELECT * FROM `root`);",
+				Args: []any{0, "top"},
+			},
+		},
+		{
+			name:    "unknown cte",
+			q:       NewSelector[TreeCategory](db).From(CTE("tree")),
+			wantErr: errs.NewErrUnknownCTE("tree"),
+		},
+		{
+			name: "unknown column",
+			q: NewSelector[TreeCategory](db).
+				With("a", NewSelector[TreeCategory](db).Select(C("Id"))).
+				Select(CTE("a").C("Name")).From(CTE("a")),
+			wantErr: errs.NewErrUnknownField("Name"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestCTE_Postgres(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(Postgres))
+	a := NewSelector[TreeCategory](db).Where(C("ParentId").EQ(1))
+	q, err := NewSelector[TreeCategory](db).With("a", a).Where(C("Id").GT(2)).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL:  `WITH "a" AS (SELECT * FROM "tree_category" WHERE "parent_id" = $1) SELECT * FROM "tree_category" WHERE "id" > $2;`,
+		Args: []any{1, 2},
+	}, q)
+}
+
+func TestCTE_Exec(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("cte", t)
+	require.NoError(t, NewCreater[TreeCategory](db).Exec(ctx).Err())
+	require.NoError(t, NewInserter[TreeCategory](db).Values(
+		&TreeCategory{Id: 1, Name: "root"},
+		&TreeCategory{Id: 2, ParentId: 1, Name: "a"},
+		&TreeCategory{Id: 3, ParentId: 2, Name: "a1"},
+		&TreeCategory{Id: 4, ParentId: 1, Name: "b"},
+		&TreeCategory{Id: 5, Name: "other"},
+	).Exec(ctx).Err())
+
+	tree := CTE("tree")
+	res, err := NewSelector[TreeCategory](db).WithRecursive("tree", categoryTree(db, 2)).
+		From(tree).OrderBy(Asc(tree.C("Id"))).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*TreeCategory{
+		{Id: 2, ParentId: 1, Name: "a"},
+		{Id: 3, ParentId: 2, Name: "a1"},
+	}, res)
+
+	// 删除 1 以及它的子孙
+	sub := NewSelector[TreeCategory](db).Select(tree.C("Id")).From(tree).AsSubquery("sub")
+	affected, err := NewDeleter[TreeCategory](db).WithRecursive("tree", categoryTree(db, 1)).
+		Where(C("Id").In(sub)).Exec(ctx).RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), affected)
+}
@@ -43,6 +43,17 @@ func DBWithValCreator(c valuer.Creator) DBOption {
 	}
 }
 
+// DBWithSafeInsertID 开启自增主键回写的安全模式。
+// 不支持 RETURNING 的方言（例如 MySQL）在批量插入之后，
+// 默认从 LastInsertId 开始按照连续的 ID 推断每一行的主键，
+// 这要求 innodb_autoinc_lock_mode 不是 2 并且 auto_increment_increment 是 1。
+// 开启安全模式之后只会回写单行插入的主键，批量插入不再推断
+func DBWithSafeInsertID() DBOption {
+	return func(db *DB) {
+		db.safeInsertID = true
+	}
+}
+
 //func DBUseUnsafeValuer() DBOption {
 //	return func(db *DB) {
 //		db.valCreator = valuer.NewUnsafeValue
@@ -20,7 +20,6 @@ func NewDeleter[T any](sess session) *Deleter[T] {
 			core:     c,
 			buffer:   bytebufferpool.Get(),
 			aliasMap: make(map[string]int, 8),
-			quoter:   c.dialect.quoter(),
 		},
 	}
 }
@@ -32,6 +31,18 @@ func (d *Deleter[T]) Where(ps ...Predicate) *Deleter[T] {
 	return d
 }
 
+// With 定义一个 CTE，一般在 WHERE 的子查询里面通过 CTE(name) 引用它
+func (d *Deleter[T]) With(name string, q QueryBuilder) *Deleter[T] {
+	d.with(name, q, false)
+	return d
+}
+
+// WithRecursive 定义一个递归的 CTE
+func (d *Deleter[T]) WithRecursive(name string, q QueryBuilder) *Deleter[T] {
+	d.with(name, q, true)
+	return d
+}
+
 // From 指定表名，如果是空字符串，那么将会使用默认表名
 func (d *Deleter[T]) From(tbl string) *Deleter[T] {
 	d.table = tbl
@@ -39,7 +50,7 @@ func (d *Deleter[T]) From(tbl string) *Deleter[T] {
 }
 
 func (d *Deleter[T]) Build() (*Query, error) {
-	defer bytebufferpool.Put(d.buffer)
+	defer d.release()
 	var (
 		t   T
 		err error
@@ -48,11 +59,12 @@ func (d *Deleter[T]) Build() (*Query, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = d.buildWith(); err != nil {
+		return nil, err
+	}
 	d.writeString("DELETE FROM ")
 	if d.table == "" {
-		d.writeByte('`')
-		d.writeString(d.model.TableName)
-		d.writeByte('`')
+		d.quote(d.model.TableName)
 	} else {
 		d.writeString(d.table)
 	}
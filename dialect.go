@@ -3,88 +3,304 @@ package orm
 import (
 	"fmt"
 	"orm/internal/errs"
+	"orm/model"
 	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
 var (
-	MySQL   Dialect = &mysqlDialect{}
-	SQLite3 Dialect = &sqlite3Dialect{}
+	MySQL Dialect = &mysqlDialect{}
+	// MySQL8 使用 MySQL 8.0.19 引入的行别名引用待插入的值，
+	// 因为从 8.0.20 开始 VALUES(col) 的写法已经被废弃
+	MySQL8   Dialect = &mysqlDialect{rowAlias: true}
+	SQLite3  Dialect = &sqlite3Dialect{}
+	Postgres Dialect = &postgresDialect{}
+	MSSQL    Dialect = &mssqlDialect{}
 )
 
+// Dialect 屏蔽了不同数据库之间的语法差异。
+// 用户可以实现该接口，并且通过 RegisterDialect 注册，从而支持新的数据库。
+//
+// 自定义方言必须组合 StandardSQL，只需要实现 ColTypeOf 和有差异的部分。
+// 之后新增的方法都会在 StandardSQL 里面提供默认实现，
+// 所以升级的时候已有的方言不需要修改。这一点由 standard 方法保证，没有组合 StandardSQL 的类型无法实现该接口
 type Dialect interface {
-	// quoter 返回一个引号，引用列名，表名的引号
-	quoter() byte
+	// standard 只有 StandardSQL 实现了，用于强制组合 StandardSQL
+	standard()
+	// Quoter 返回引用列名，表名的左右引号。
+	// 大多数数据库左右引号是一样的，但是 SQL Server 使用的是 [ 和 ]
+	Quoter() (left byte, right byte)
+	// Placeholder 返回第 index 个参数的占位符，index 从 1 开始
+	Placeholder(index int) string
 	ColTypeOf(typ reflect.Value) string
-	// buildOnConflict 构造插入冲突部分
-	buildOnConflict(b *Builder, odk *OnConflict) error
+	// BuildLimitOffset 构造分页部分，limit 和 offset 至少有一个大于 0。
+	// ordered 表示查询是否已经有 ORDER BY 子句
+	BuildLimitOffset(b *Builder, limit, offset int, ordered bool) error
+	// BuildUpsert 构造完整的 upsert 语句。
+	// 大多数方言只是在 INSERT 语句后面追加冲突处理部分，
+	// 但是 SQL Server 需要用 MERGE 改写整个语句，所以交给方言来构造
+	BuildUpsert(b *Builder, u *Upsert) error
+	// BuildExcluded 构造 upsert 中字段 fd 待插入的值，例如 SQLite 的 excluded.col
+	BuildExcluded(b *Builder, u *Upsert, fd *model.Field) error
+	// SupportReturning 是否支持 RETURNING 子句
+	SupportReturning() bool
+	// AutoIncrement 返回自增列的类型定义，colType 是该列原本的类型
+	AutoIncrement(colType string) string
+	// SupportInlineIndex 是否支持在 CREATE TABLE 里面直接定义普通索引，
+	// 不支持的话会使用单独的 CREATE INDEX 语句
+	SupportInlineIndex() bool
+	// BuildFunc 构造 Lower、Now、DateFormat 这些辅助方法创建的函数调用，
+	// 不同数据库的函数名和参数顺序可能不一样。Func 创建的函数不会经过这里
+	BuildFunc(b *Builder, f FuncExpr) error
+	// BuildLock 构造 SELECT 末尾的行锁部分，例如 FOR UPDATE SKIP LOCKED
+	BuildLock(b *Builder, l Lock) error
+	// SupportNullsOrder 是否支持 ORDER BY 里面的 NULLS FIRST 和 NULLS LAST，
+	// 不支持的话会使用 CASE WHEN 来模拟
+	SupportNullsOrder() bool
+	// SupportUpdateJoin 是否支持 UPDATE a JOIN b ON ... SET ... 的写法
+	SupportUpdateJoin() bool
+	// SupportUpdateLimit 是否支持 UPDATE 里面的 ORDER BY 和 LIMIT，
+	// LIMIT 部分依旧通过 BuildLimitOffset 构造
+	SupportUpdateLimit() bool
+	// SupportParenthesizedOperand 集合操作的子查询能不能用括号括起来，
+	// 不支持的话有 ORDER BY、LIMIT 或者 OFFSET 的子查询会被包装成 SELECT * FROM (...)
+	SupportParenthesizedOperand() bool
+	// MaxArgs 一条语句最多可以使用的参数个数，
+	// Inserter 没有指定 BatchSize 的时候会据此拆分批量插入
+	MaxArgs() int
+}
+
+// Lock 是 SELECT 的行锁设置，由 Selector.ForUpdate 这些方法设置
+type Lock struct {
+	// Share 为 true 的时候是共享锁，否则是排他锁
+	Share bool
+	// SkipLocked 跳过已经被锁住的行
+	SkipLocked bool
+	// NoWait 行已经被锁住的时候直接返回错误，而不是等待
+	NoWait bool
+}
+
+var (
+	dialectsMu sync.RWMutex
+	// dialects 驱动名到方言的映射
+	dialects = map[string]Dialect{
+		"sqlite3":   SQLite3,
+		"mysql":     MySQL,
+		"postgres":  Postgres,
+		"pgx":       Postgres,
+		"sqlserver": MSSQL,
+		"mssql":     MSSQL,
+	}
+)
+
+// RegisterDialect 注册驱动对应的方言，之后 Open 和 OpenDB 就可以使用该驱动。
+// 例如 TiDB 兼容 MySQL 协议，那么可以 RegisterDialect("tidb", MySQL)。
+// 重复注册会覆盖已有的方言，d 为 nil 的时候会 panic
+func RegisterDialect(driverName string, d Dialect) {
+	if d == nil {
+		panic("orm: RegisterDialect dialect is nil")
+	}
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[driverName] = d
 }
 
 func dialectOf(driver string) (Dialect, error) {
-	switch driver {
-	case "sqlite3":
-		return SQLite3, nil
-	case "mysql":
-		return MySQL, nil
-	default:
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	d, ok := dialects[driver]
+	if !ok {
 		return nil, errs.NewUnsupportedDriverError(driver)
 	}
+	return d, nil
 }
 
-type standardSQL struct{}
+// StandardSQL 提供了标准 SQL 的默认实现，自定义方言必须组合它。
+// 注意它没有实现 ColTypeOf，组合它的方言必须自己实现
+type StandardSQL struct{}
+
+func (d *StandardSQL) standard() {}
 
-func (d *standardSQL) quoter() byte {
-	// TODO implement me
-	panic("implement me")
+// Quoter 标准 SQL 使用双引号
+func (d *StandardSQL) Quoter() (byte, byte) {
+	return '"', '"'
 }
 
-func (d *standardSQL) buildOnConflict(b *Builder, odk *OnConflict) error {
-	// TODO implement me
-	panic("implement me")
+func (d *StandardSQL) Placeholder(index int) string {
+	return "?"
 }
 
-func (d *standardSQL) ColTypeOf(typ reflect.Value) string {
-	// TODO implement me
-	panic("implement me")
+func (d *StandardSQL) SupportReturning() bool {
+	return false
 }
 
-type mysqlDialect struct {
-	standardSQL
+// SupportUpdateJoin 标准 SQL 里面 UPDATE 只能更新一张表
+func (d *StandardSQL) SupportUpdateJoin() bool {
+	return false
 }
 
-func (d *mysqlDialect) quoter() byte {
-	return '`'
+func (d *StandardSQL) SupportUpdateLimit() bool {
+	return false
 }
 
-func (d *mysqlDialect) buildOnConflict(b *Builder, odk *OnConflict) error {
-	b.writeString(" ON DUPLICATE KEY UPDATE ")
-	for pos, assign := range odk.assigns {
-		if pos > 0 {
-			b.writeComma()
-		}
-		switch a := assign.(type) {
-		case Assignment:
-			if err := b.buildAssignment(a); err != nil {
-				return err
+func (d *StandardSQL) SupportParenthesizedOperand() bool {
+	return true
+}
+
+// MaxArgs MySQL 和 PostgreSQL 的协议都是用两个字节表示参数个数
+func (d *StandardSQL) MaxArgs() int {
+	return 65535
+}
+
+// BuildLimitOffset 绝大多数数据库都支持 LIMIT ... OFFSET ... 的写法
+func (d *StandardSQL) BuildLimitOffset(b *Builder, limit, offset int, ordered bool) error {
+	if limit > 0 {
+		b.writeString(" LIMIT ")
+		b.writePlaceholder()
+		b.addArgs(limit)
+	}
+	if offset > 0 {
+		b.writeString(" OFFSET ")
+		b.writePlaceholder()
+		b.addArgs(offset)
+	}
+	return nil
+}
+
+// AutoIncrement 使用 SQL:2003 标准的 IDENTITY 列
+func (d *StandardSQL) AutoIncrement(colType string) string {
+	return colType + " GENERATED BY DEFAULT AS IDENTITY"
+}
+
+func (d *StandardSQL) SupportInlineIndex() bool {
+	return false
+}
+
+// BuildFunc 标准 SQL 使用 || 拼接字符串，CHAR_LENGTH 计算字符数，
+// 并且没有格式化时间的函数
+func (d *StandardSQL) BuildFunc(b *Builder, f FuncExpr) error {
+	switch f.name {
+	case funcNow:
+		b.writeString("CURRENT_TIMESTAMP")
+		return nil
+	case funcConcat:
+		b.writeLeftParenthesis()
+		for i, arg := range f.args {
+			if i > 0 {
+				b.writeString(" || ")
 			}
-		case Column:
-			if err := d.buildConflictColumn(b, a); err != nil {
+			if err := b.buildExpression(arg, false, false); err != nil {
 				return err
 			}
-		default:
-			return errs.NewErrUnsupportedAssignableType(assign)
 		}
+		b.writeRightParenthesis()
+		return nil
+	case funcLength:
+		return b.buildFuncCall("CHAR_LENGTH", f.args...)
+	case funcDateFormat:
+		return errs.NewErrUnsupportedFunc(f.name)
+	default:
+		return b.buildFuncCall(f.name, f.args...)
+	}
+}
+
+// SupportNullsOrder NULLS FIRST 和 NULLS LAST 是 SQL:2003 标准的一部分，
+// PostgreSQL 和 SQLite 3.30 以后都支持
+func (d *StandardSQL) SupportNullsOrder() bool {
+	return true
+}
+
+// BuildLock 使用 FOR UPDATE 和 FOR SHARE，MySQL 8.0 和 PostgreSQL 都支持这种写法
+func (d *StandardSQL) BuildLock(b *Builder, l Lock) error {
+	if l.Share {
+		b.writeString(" FOR SHARE")
+	} else {
+		b.writeString(" FOR UPDATE")
+	}
+	switch {
+	case l.SkipLocked:
+		b.writeString(" SKIP LOCKED")
+	case l.NoWait:
+		b.writeString(" NOWAIT")
 	}
 	return nil
 }
 
-func (d *mysqlDialect) buildConflictColumn(b *Builder, c Column) error {
-	fd, ok := b.model.FieldMap[c.name]
+// buildDateFormat 构造 name(arg, layout) 或者 name(layout, arg)，
+// layout 会先用 r 转换成数据库的格式，然后作为参数传入。
+// layout 不是字符串的时候返回错误
+func buildDateFormat(b *Builder, f FuncExpr, name string, r *strings.Replacer, layoutFirst bool) error {
+	if len(f.args) != 2 {
+		return errs.NewErrUnsupportedExpressionType(f)
+	}
+	val, ok := f.args[1].(value)
 	if !ok {
-		return errs.NewErrUnknownField(c.name)
+		return errs.NewErrUnsupportedExpressionType(f.args[1])
+	}
+	layout, ok := val.val.(string)
+	if !ok {
+		return errs.NewErrUnsupportedExpressionType(f.args[1])
+	}
+	format := value{val: r.Replace(layout)}
+	if layoutFirst {
+		return b.buildFuncCall(name, format, f.args[0])
+	}
+	return b.buildFuncCall(name, f.args[0], format)
+}
+
+// BuildUpsert 标准 SQL 里面并没有 upsert 的语法
+func (d *StandardSQL) BuildUpsert(b *Builder, u *Upsert) error {
+	return errs.ErrUnsupportedUpsert
+}
+
+func (d *StandardSQL) BuildExcluded(b *Builder, u *Upsert, fd *model.Field) error {
+	return errs.ErrUnsupportedUpsert
+}
+
+// mysqlRowAlias 是 MySQL8 里面待插入的行的别名
+const mysqlRowAlias = "new"
+
+type mysqlDialect struct {
+	StandardSQL
+	// rowAlias 是否使用行别名引用待插入的值
+	rowAlias bool
+}
+
+func (d *mysqlDialect) Quoter() (byte, byte) {
+	return '`', '`'
+}
+
+// BuildUpsert MySQL 冲突时忽略使用的是 INSERT IGNORE，并且不支持更新条件
+func (d *mysqlDialect) BuildUpsert(b *Builder, u *Upsert) error {
+	odk := u.OnConflict
+	if odk.doNothing {
+		return b.buildInsert("INSERT IGNORE", u)
+	}
+	if len(odk.where) > 0 {
+		return errs.ErrUnsupportedUpsertWhere
+	}
+	if err := b.buildInsert("INSERT", u); err != nil {
+		return err
+	}
+	if d.useRowAlias(u) {
+		b.writeString(" AS ")
+		b.quote(mysqlRowAlias)
+	}
+	b.writeString(" ON DUPLICATE KEY UPDATE ")
+	return b.buildConflictAssigns(odk.assigns)
+}
+
+// BuildExcluded MySQL 使用 VALUES(col) 或者 new.col
+func (d *mysqlDialect) BuildExcluded(b *Builder, u *Upsert, fd *model.Field) error {
+	if d.useRowAlias(u) {
+		b.quote(mysqlRowAlias)
+		b.writeByte('.')
+		b.quote(fd.ColName)
+		return nil
 	}
-	b.quote(fd.ColName)
-	b.writeString(" = ")
 	b.writeString("VALUES")
 	b.writeLeftParenthesis()
 	b.quote(fd.ColName)
@@ -92,6 +308,49 @@ func (d *mysqlDialect) buildConflictColumn(b *Builder, c Column) error {
 	return nil
 }
 
+// useRowAlias 行别名只能用在 VALUES 后面，INSERT ... SELECT 依旧使用 VALUES(col)
+func (d *mysqlDialect) useRowAlias(u *Upsert) bool {
+	return d.rowAlias && u.Select == nil
+}
+
+// mysqlDateLayout 将 Go 的时间格式转换成 DATE_FORMAT 的格式
+var mysqlDateLayout = strings.NewReplacer("%", "%%",
+	"2006", "%Y", "01", "%m", "02", "%d", "15", "%H", "04", "%i", "05", "%s")
+
+func (d *mysqlDialect) BuildFunc(b *Builder, f FuncExpr) error {
+	switch f.name {
+	case funcNow:
+		b.writeString("NOW()")
+		return nil
+	case funcConcat:
+		return b.buildFuncCall(funcConcat, f.args...)
+	case funcDateFormat:
+		return buildDateFormat(b, f, "DATE_FORMAT", mysqlDateLayout, false)
+	default:
+		return d.StandardSQL.BuildFunc(b, f)
+	}
+}
+
+func (d *mysqlDialect) SupportNullsOrder() bool {
+	return false
+}
+
+func (d *mysqlDialect) SupportUpdateJoin() bool {
+	return true
+}
+
+func (d *mysqlDialect) SupportUpdateLimit() bool {
+	return true
+}
+
+func (d *mysqlDialect) AutoIncrement(colType string) string {
+	return colType + " AUTO_INCREMENT"
+}
+
+func (d *mysqlDialect) SupportInlineIndex() bool {
+	return true
+}
+
 func (d *mysqlDialect) ColTypeOf(typ reflect.Value) string {
 	switch typ.Kind() {
 	case reflect.Bool:
@@ -116,11 +375,55 @@ func (d *mysqlDialect) ColTypeOf(typ reflect.Value) string {
 }
 
 type sqlite3Dialect struct {
-	standardSQL
+	StandardSQL
+}
+
+func (d *sqlite3Dialect) Quoter() (byte, byte) {
+	return '`', '`'
+}
+
+// SupportReturning SQLite 从 3.35.0 开始支持 RETURNING
+func (d *sqlite3Dialect) SupportReturning() bool {
+	return true
+}
+
+// SupportParenthesizedOperand SQLite 的语法不允许 (SELECT ...) UNION (SELECT ...)
+func (d *sqlite3Dialect) SupportParenthesizedOperand() bool {
+	return false
+}
+
+// MaxArgs SQLite 从 3.32.0 开始 SQLITE_MAX_VARIABLE_NUMBER 默认是 32766，之前是 999
+func (d *sqlite3Dialect) MaxArgs() int {
+	return 32766
+}
+
+// AutoIncrement SQLite 中只有类型恰好是 INTEGER 的主键才是 rowid 的别名，
+// 插入时不指定的话会自动生成
+func (d *sqlite3Dialect) AutoIncrement(colType string) string {
+	return "integer"
 }
 
-func (d *sqlite3Dialect) quoter() byte {
-	return '`'
+// BuildLock SQLite 锁的是整个数据库，没有行锁，
+// 需要的话可以使用 BEGIN IMMEDIATE 开启事务
+func (d *sqlite3Dialect) BuildLock(b *Builder, l Lock) error {
+	return errs.ErrUnsupportedLock
+}
+
+// sqlite3DateLayout 将 Go 的时间格式转换成 strftime 的格式
+var sqlite3DateLayout = strings.NewReplacer("%", "%%",
+	"2006", "%Y", "01", "%m", "02", "%d", "15", "%H", "04", "%M", "05", "%S")
+
+// BuildFunc SQLite 没有 CHAR_LENGTH，LENGTH 对于字符串返回的就是字符数，
+// 并且使用 strftime 格式化时间
+func (d *sqlite3Dialect) BuildFunc(b *Builder, f FuncExpr) error {
+	switch f.name {
+	case funcLength:
+		return b.buildFuncCall(funcLength, f.args...)
+	case funcDateFormat:
+		return buildDateFormat(b, f, "strftime", sqlite3DateLayout, true)
+	default:
+		return d.StandardSQL.BuildFunc(b, f)
+	}
 }
 
 func (d *sqlite3Dialect) ColTypeOf(typ reflect.Value) string {
@@ -147,7 +450,36 @@ func (d *sqlite3Dialect) ColTypeOf(typ reflect.Value) string {
 
 }
 
-func (d *sqlite3Dialect) buildOnConflict(b *Builder, odk *OnConflict) error {
+// BuildUpsert SQLite 在 INSERT ... SELECT 后面直接跟 ON CONFLICT 会有语法歧义，
+// 所以要把查询包起来并加上 WHERE true
+func (d *sqlite3Dialect) BuildUpsert(b *Builder, u *Upsert) error {
+	if u.Select == nil {
+		if err := b.buildInsert("INSERT", u); err != nil {
+			return err
+		}
+	} else {
+		b.writeString("INSERT INTO ")
+		b.quote(u.Table)
+		b.buildFieldList(u.Fields)
+		b.writeString(" SELECT * FROM (")
+		if err := b.buildInsertSource(u); err != nil {
+			return err
+		}
+		b.writeString(") WHERE true")
+	}
+	return buildOnConflict(b, u.OnConflict)
+}
+
+// BuildExcluded SQLite 使用 excluded.col
+func (d *sqlite3Dialect) BuildExcluded(b *Builder, u *Upsert, fd *model.Field) error {
+	b.writeString("excluded.")
+	b.quote(fd.ColName)
+	return nil
+}
+
+// buildOnConflict 构造 SQLite 和 PostgreSQL 的
+// ON CONFLICT(col) DO NOTHING 或者 ON CONFLICT(col) DO UPDATE SET ... WHERE ... 部分
+func buildOnConflict(b *Builder, odk *OnConflict) error {
 	b.writeString(" ON CONFLICT")
 	if len(odk.conflictColumns) > 0 {
 		b.writeLeftParenthesis()
@@ -163,35 +495,283 @@ func (d *sqlite3Dialect) buildOnConflict(b *Builder, odk *OnConflict) error {
 		}
 		b.writeRightParenthesis()
 	}
+	if odk.doNothing {
+		b.writeString(" DO NOTHING")
+		return nil
+	}
 	b.writeString(" DO UPDATE SET ")
-	for pos, assign := range odk.assigns {
-		if pos > 0 {
-			b.writeComma()
+	if err := b.buildConflictAssigns(odk.assigns); err != nil {
+		return err
+	}
+	if len(odk.where) > 0 {
+		b.writeString(" WHERE ")
+		return b.buildPredicates(&predicates{ps: odk.where})
+	}
+	return nil
+}
+
+type postgresDialect struct {
+	StandardSQL
+}
+
+func (d *postgresDialect) Quoter() (byte, byte) {
+	return '"', '"'
+}
+
+// Placeholder PostgreSQL 使用的是 $1, $2 这种带编号的占位符
+func (d *postgresDialect) Placeholder(index int) string {
+	return "$" + strconv.Itoa(index)
+}
+
+func (d *postgresDialect) SupportReturning() bool {
+	return true
+}
+
+// postgresDateLayout 将 Go 的时间格式转换成 TO_CHAR 的格式
+var postgresDateLayout = strings.NewReplacer(
+	"2006", "YYYY", "01", "MM", "02", "DD", "15", "HH24", "04", "MI", "05", "SS")
+
+func (d *postgresDialect) BuildFunc(b *Builder, f FuncExpr) error {
+	switch f.name {
+	case funcNow:
+		b.writeString("NOW()")
+		return nil
+	case funcDateFormat:
+		return buildDateFormat(b, f, "TO_CHAR", postgresDateLayout, false)
+	default:
+		return d.StandardSQL.BuildFunc(b, f)
+	}
+}
+
+func (d *postgresDialect) ColTypeOf(typ reflect.Value) string {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int8, reflect.Int16, reflect.Uint8:
+		return "smallint"
+	case reflect.Int, reflect.Int32, reflect.Uint16:
+		return "integer"
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "bigint"
+	case reflect.Float32:
+		return "real"
+	case reflect.Float64:
+		return "double precision"
+	case reflect.String:
+		return "text"
+	case reflect.Array, reflect.Slice:
+		return "bytea"
+	case reflect.Struct:
+		if _, ok := typ.Interface().(time.Time); ok {
+			return "timestamp"
 		}
-		switch a := assign.(type) {
-		case Assignment:
-			if err := b.buildAssignment(a); err != nil {
-				return err
-			}
-		case Column:
-			if err := d.buildConflictColumn(b, a); err != nil {
-				return err
-			}
-		default:
-			return errs.NewErrUnsupportedAssignableType(assign)
+	}
+	panic(fmt.Sprintf("invalid sql type %s (%s)", typ.Type().Name(), typ.Kind()))
+}
+
+// BuildUpsert PostgreSQL 的 DO UPDATE 必须指定冲突列，DO NOTHING 可以不指定
+func (d *postgresDialect) BuildUpsert(b *Builder, u *Upsert) error {
+	odk := u.OnConflict
+	if len(odk.conflictColumns) == 0 && !odk.doNothing {
+		return errs.ErrNoConflictColumns
+	}
+	if err := b.buildInsert("INSERT", u); err != nil {
+		return err
+	}
+	// DO UPDATE 里面单独的列名有歧义，既可以是目标表的，也可以是 EXCLUDED 的
+	b.qualifier = u.Table
+	defer func() {
+		b.qualifier = ""
+	}()
+	return buildOnConflict(b, odk)
+}
+
+// BuildExcluded PostgreSQL 使用 EXCLUDED.col
+func (d *postgresDialect) BuildExcluded(b *Builder, u *Upsert, fd *model.Field) error {
+	b.writeString("EXCLUDED.")
+	b.quote(fd.ColName)
+	return nil
+}
+
+const (
+	// mergeTarget 和 mergeSource 是 MERGE 语句中目标表和待插入数据的别名
+	mergeTarget = "target"
+	mergeSource = "excluded"
+)
+
+type mssqlDialect struct {
+	StandardSQL
+}
+
+func (d *mssqlDialect) Quoter() (byte, byte) {
+	return '[', ']'
+}
+
+// Placeholder SQL Server 使用的是 @p1, @p2 这种带编号的命名参数
+func (d *mssqlDialect) Placeholder(index int) string {
+	return "@p" + strconv.Itoa(index)
+}
+
+func (d *mssqlDialect) SupportNullsOrder() bool {
+	return false
+}
+
+// MaxArgs SQL Server 一个请求最多 2100 个参数
+func (d *mssqlDialect) MaxArgs() int {
+	return 2100
+}
+
+func (d *mssqlDialect) AutoIncrement(colType string) string {
+	return colType + " IDENTITY(1,1)"
+}
+
+func (d *mssqlDialect) SupportInlineIndex() bool {
+	return true
+}
+
+// BuildLock SQL Server 使用 WITH (UPDLOCK) 这种表提示来加锁，暂不支持
+func (d *mssqlDialect) BuildLock(b *Builder, l Lock) error {
+	return errs.ErrUnsupportedLock
+}
+
+// mssqlDateLayout 将 Go 的时间格式转换成 FORMAT 的格式
+var mssqlDateLayout = strings.NewReplacer(
+	"2006", "yyyy", "01", "MM", "02", "dd", "15", "HH", "04", "mm", "05", "ss")
+
+// BuildFunc SQL Server 使用 LEN 计算字符数，注意它会忽略末尾的空格
+func (d *mssqlDialect) BuildFunc(b *Builder, f FuncExpr) error {
+	switch f.name {
+	case funcLength:
+		return b.buildFuncCall("LEN", f.args...)
+	case funcConcat:
+		return b.buildFuncCall(funcConcat, f.args...)
+	case funcDateFormat:
+		return buildDateFormat(b, f, "FORMAT", mssqlDateLayout, false)
+	default:
+		return d.StandardSQL.BuildFunc(b, f)
+	}
+}
+
+func (d *mssqlDialect) ColTypeOf(typ reflect.Value) string {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "bit"
+	case reflect.Uint8:
+		return "tinyint"
+	case reflect.Int8, reflect.Int16:
+		return "smallint"
+	case reflect.Int, reflect.Int32, reflect.Uint16:
+		return "int"
+	case reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return "bigint"
+	case reflect.Float32:
+		return "real"
+	case reflect.Float64:
+		return "float"
+	case reflect.String:
+		return "nvarchar(max)"
+	case reflect.Array, reflect.Slice:
+		return "varbinary(max)"
+	case reflect.Struct:
+		if _, ok := typ.Interface().(time.Time); ok {
+			return "datetime2"
 		}
 	}
+	panic(fmt.Sprintf("invalid sql type %s (%s)", typ.Type().Name(), typ.Kind()))
+}
+
+// BuildLimitOffset SQL Server 使用 OFFSET n ROWS FETCH NEXT m ROWS ONLY 来分页，
+// 它同样可以表达 TOP 的语义。
+// 注意 OFFSET 必须跟在 ORDER BY 后面，所以没有排序的时候使用 ORDER BY (SELECT NULL)
+func (d *mssqlDialect) BuildLimitOffset(b *Builder, limit, offset int, ordered bool) error {
+	if !ordered {
+		b.writeString(" ORDER BY (SELECT NULL)")
+	}
+	b.writeString(" OFFSET ")
+	if offset > 0 {
+		b.writePlaceholder()
+		b.addArgs(offset)
+	} else {
+		b.writeByte('0')
+	}
+	b.writeString(" ROWS")
+	if limit > 0 {
+		b.writeString(" FETCH NEXT ")
+		b.writePlaceholder()
+		b.addArgs(limit)
+		b.writeString(" ROWS ONLY")
+	}
 	return nil
 }
 
-func (d *sqlite3Dialect) buildConflictColumn(b *Builder, c Column) error {
-	fd, ok := b.model.FieldMap[c.name]
-	if !ok {
-		return errs.NewErrUnknownField(c.name)
+// BuildUpsert SQL Server 不支持 ON DUPLICATE KEY 或者 ON CONFLICT，
+// 所以使用 MERGE 语句，将待插入的数据作为 excluded 表，并用冲突列作为匹配条件
+func (d *mssqlDialect) BuildUpsert(b *Builder, u *Upsert) error {
+	odk := u.OnConflict
+	if len(odk.conflictColumns) == 0 {
+		return errs.ErrNoConflictColumns
 	}
-	b.quote(fd.ColName)
-	b.writeString(" = ")
-	b.writeString("excluded.")
+	if len(odk.where) > 0 {
+		return errs.ErrUnsupportedUpsertWhere
+	}
+	b.writeString("MERGE INTO ")
+	b.quote(u.Table)
+	b.writeString(" AS ")
+	b.quote(mergeTarget)
+	b.writeString(" USING (")
+	if err := b.buildInsertSource(u); err != nil {
+		return err
+	}
+	b.writeString(") AS ")
+	b.quote(mergeSource)
+	b.buildFieldList(u.Fields)
+	b.writeString(" ON ")
+	for i, col := range odk.conflictColumns {
+		if i > 0 {
+			b.writeString(" AND ")
+		}
+		fd, ok := b.model.FieldMap[col]
+		if !ok {
+			return errs.NewErrUnknownField(col)
+		}
+		b.quote(mergeTarget)
+		b.writeByte('.')
+		b.quote(fd.ColName)
+		b.writeString(" = ")
+		b.quote(mergeSource)
+		b.writeByte('.')
+		b.quote(fd.ColName)
+	}
+	if len(odk.assigns) > 0 {
+		b.writeString(" WHEN MATCHED THEN UPDATE SET ")
+		// 和 PostgreSQL 一样，单独的列名既可以是目标表的，也可以是待插入数据的
+		b.qualifier = mergeTarget
+		err := b.buildConflictAssigns(odk.assigns)
+		b.qualifier = ""
+		if err != nil {
+			return err
+		}
+	}
+	b.writeString(" WHEN NOT MATCHED THEN INSERT")
+	b.buildFieldList(u.Fields)
+	b.writeString(" VALUES")
+	b.writeLeftParenthesis()
+	for i, fd := range u.Fields {
+		if i > 0 {
+			b.writeComma()
+		}
+		b.quote(mergeSource)
+		b.writeByte('.')
+		b.quote(fd.ColName)
+	}
+	b.writeRightParenthesis()
+	return nil
+}
+
+// BuildExcluded SQL Server 使用 MERGE 语句里面待插入数据的别名
+func (d *mssqlDialect) BuildExcluded(b *Builder, u *Upsert, fd *model.Field) error {
+	b.quote(mergeSource)
+	b.writeByte('.')
 	b.quote(fd.ColName)
 	return nil
 }
@@ -0,0 +1,559 @@
+package orm
+
+import (
+	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"orm/internal/errs"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestDialectOf(t *testing.T) {
+	testCases := []struct {
+		name        string
+		driver      string
+		wantDialect Dialect
+		wantErr     error
+	}{
+		{
+			name:        "mysql",
+			driver:      "mysql",
+			wantDialect: MySQL,
+		},
+		{
+			name:        "sqlite3",
+			driver:      "sqlite3",
+			wantDialect: SQLite3,
+		},
+		{
+			name:        "postgres",
+			driver:      "postgres",
+			wantDialect: Postgres,
+		},
+		{
+			name:        "pgx",
+			driver:      "pgx",
+			wantDialect: Postgres,
+		},
+		{
+			name:        "sqlserver",
+			driver:      "sqlserver",
+			wantDialect: MSSQL,
+		},
+		{
+			name:    "unknown",
+			driver:  "oracle",
+			wantErr: errs.NewUnsupportedDriverError("oracle"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := dialectOf(tc.driver)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantDialect, d)
+		})
+	}
+}
+
+func TestPostgres_Build(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(Postgres))
+	type Order struct {
+		Id      int
+		UserId  int
+		Price   int
+		Comment string
+	}
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "select where",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").GT(18), C("FirstName").EQ("Tom")),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" WHERE ("age" > $1) AND ("first_name" = $2);`,
+				Args: []any{18, "Tom"},
+			},
+		},
+		{
+			name: "select order by limit offset",
+			q: NewSelector[TestModel](db).Where(C("Age").GT(18)).
+				OrderBy(Desc("Age")).Limit(10).Offset(20),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" WHERE "age" > $1 ORDER BY "age" DESC LIMIT $2 OFFSET $3;`,
+				Args: []any{18, 10, 20},
+			},
+		},
+		{
+			name: "select subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[Order](db).Select(C("UserId")).
+					Where(C("Price").GT(100)).AsSubquery("sub")
+				return NewSelector[TestModel](db).
+					Where(C("Age").GT(18), C("Id").In(sub), C("FirstName").EQ("Tom"))
+			}(),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" WHERE (("age" > $1) AND ("id" IN (SELECT "user_id" FROM "order" WHERE "price" > $2))) AND ("first_name" = $3);`,
+				Args: []any{18, 100, "Tom"},
+			},
+		},
+		{
+			name: "select in",
+			q: NewSelector[TestModel](db).
+				Where(C("Id").In([]int64{1, 2, 3}), C("Age").GT(18)),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" WHERE ("id" IN ($1,$2,$3)) AND ("age" > $4);`,
+				Args: []any{int64(1), int64(2), int64(3), 18},
+			},
+		},
+		{
+			name: "select join",
+			q: func() QueryBuilder {
+				t1 := TableOf(&TestModel{}).As("t1")
+				t2 := TableOf(&Order{}).As("t2")
+				return NewSelector[TestModel](db).
+					From(t1.Join(t2).On(t1.C("Id").EQ(t2.C("UserId")))).
+					Where(t2.C("Price").GT(100))
+			}(),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM ("test_model" AS "t1" JOIN "order" AS "t2" ON "t1"."id" = "t2"."user_id") WHERE "t2"."price" > $1;`,
+				Args: []any{100},
+			},
+		},
+		{
+			name: "union",
+			q: func() QueryBuilder {
+				s1 := NewSelector[Order](db).Where(C("Price").GT(100))
+				s2 := NewSelector[Order](db).Where(C("UserId").EQ(3))
+				return s1.Union(s2)
+			}(),
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "order" WHERE "price" > $1 UNION SELECT * FROM "order" WHERE "user_id" = $2;`,
+				Args: []any{100, 3},
+			},
+		},
+		{
+			name: "insert",
+			q: NewInserter[TestModel](db).Values(
+				&TestModel{
+					Id:        1,
+					FirstName: "Deng",
+					Age:       18,
+					LastName:  &sql.NullString{String: "Ming", Valid: true},
+				},
+				&TestModel{
+					Id:        2,
+					FirstName: "Da",
+					Age:       19,
+					LastName:  &sql.NullString{String: "Ming", Valid: true},
+				}),
+			wantQuery: &Query{
+				SQL: `INSERT INTO "test_model"("id","first_name","age","last_name") VALUES($1,$2,$3,$4),($5,$6,$7,$8);`,
+				Args: []any{int64(1), "Deng", int8(18), &sql.NullString{String: "Ming", Valid: true},
+					int64(2), "Da", int8(19), &sql.NullString{String: "Ming", Valid: true}},
+			},
+		},
+		{
+			name: "upsert",
+			q: NewInserter[TestModel](db).Values(
+				&TestModel{
+					Id:        1,
+					FirstName: "Deng",
+					Age:       18,
+					LastName:  &sql.NullString{String: "Ming", Valid: true},
+				}).OnConflictKey().ConflictColumns("Id").
+				Update(Assign("FirstName", "Da"), C("LastName")),
+			wantQuery: &Query{
+				SQL: `INSERT INTO "test_model"("id","first_name","age","last_name") VALUES($1,$2,$3,$4) ` +
+					`ON CONFLICT("id") DO UPDATE SET "first_name" = $5,"last_name" = EXCLUDED."last_name";`,
+				Args: []any{int64(1), "Deng", int8(18), &sql.NullString{String: "Ming", Valid: true}, "Da"},
+			},
+		},
+		{
+			name: "upsert without conflict columns",
+			q: NewInserter[TestModel](db).Values(&TestModel{}).
+				OnConflictKey().Update(C("FirstName")),
+			wantErr: errs.ErrNoConflictColumns,
+		},
+		{
+			name: "insert select",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).Where(C("Age").GT(18))).
+				OnConflictKey().ConflictColumns("Id").Update(Assign("Age", 1)),
+			wantQuery: &Query{
+				SQL: `INSERT INTO "test_model"("id","first_name") SELECT "id","first_name" FROM "test_model" WHERE "age" > $1 ` +
+					`ON CONFLICT("id") DO UPDATE SET "age" = $2;`,
+				Args: []any{18, 1},
+			},
+		},
+		{
+			name: "upsert where",
+			q: NewInserter[TestModel](db).Columns("Id", "Age").
+				Values(&TestModel{Id: 1, Age: 18}).
+				OnConflictKey().ConflictColumns("Id").Where(C("Age").LT(Excluded("Age"))).
+				Update(Assign("Age", C("Age").Add(Excluded("Age"))), Assign("FirstName", "Tom")),
+			wantQuery: &Query{
+				SQL: `INSERT INTO "test_model"("id","age") VALUES($1,$2) ON CONFLICT("id") ` +
+					`DO UPDATE SET "age" = "test_model"."age" + EXCLUDED."age","first_name" = $3 WHERE "test_model"."age" < EXCLUDED."age";`,
+				Args: []any{int64(1), int8(18), "Tom"},
+			},
+		},
+		{
+			// DO NOTHING 可以不指定冲突列
+			name: "do nothing",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				Values(&TestModel{Id: 1, FirstName: "Deng"}).OnConflictKey().DoNothing(),
+			wantQuery: &Query{
+				SQL:  `INSERT INTO "test_model"("id","first_name") VALUES($1,$2) ON CONFLICT DO NOTHING;`,
+				Args: []any{int64(1), "Deng"},
+			},
+		},
+		{
+			name: "do nothing with conflict columns",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				Values(&TestModel{Id: 1, FirstName: "Deng"}).OnConflictKey().ConflictColumns("Id").DoNothing(),
+			wantQuery: &Query{
+				SQL:  `INSERT INTO "test_model"("id","first_name") VALUES($1,$2) ON CONFLICT("id") DO NOTHING;`,
+				Args: []any{int64(1), "Deng"},
+			},
+		},
+		{
+			name: "returning",
+			q: NewInserter[TestModel](db).Columns("FirstName", "Age").
+				Values(&TestModel{FirstName: "Deng", Age: 18}).Returning("Id"),
+			wantQuery: &Query{
+				SQL:  `INSERT INTO "test_model"("first_name","age") VALUES($1,$2) RETURNING "id";`,
+				Args: []any{"Deng", int8(18)},
+			},
+		},
+		{
+			name: "returning invalid column",
+			q: NewInserter[TestModel](db).
+				Values(&TestModel{FirstName: "Deng", Age: 18}).Returning("Invalid"),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "update",
+			q: NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).
+				Set(C("Age"), Assign("FirstName", "Tom")).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  `UPDATE "test_model" SET "age" = $1,"first_name" = $2 WHERE "id" = $3;`,
+				Args: []any{int8(18), "Tom", 1},
+			},
+		},
+		{
+			name: "delete",
+			q:    NewDeleter[TestModel](db).Where(C("Id").EQ(16).Or(C("Age").LT(10))),
+			wantQuery: &Query{
+				SQL:  `DELETE FROM "test_model" WHERE ("id" = $1) OR ("age" < $2);`,
+				Args: []any{16, 10},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestMSSQL_Build(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(MSSQL))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "select where",
+			q:    NewSelector[TestModel](db).Where(C("Age").GT(18)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM [test_model] WHERE [age] > @p1;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "limit only",
+			q:    NewSelector[TestModel](db).Limit(10),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM [test_model] ORDER BY (SELECT NULL) OFFSET 0 ROWS FETCH NEXT @p1 ROWS ONLY;",
+				Args: []any{10},
+			},
+		},
+		{
+			name: "offset only",
+			q:    NewSelector[TestModel](db).OrderBy(Asc("Id")).Offset(10),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM [test_model] ORDER BY [id] ASC OFFSET @p1 ROWS;",
+				Args: []any{10},
+			},
+		},
+		{
+			name: "limit offset",
+			q: NewSelector[TestModel](db).Where(C("Age").GT(18)).
+				OrderBy(Desc("Age")).Limit(20).Offset(10),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM [test_model] WHERE [age] > @p1 ORDER BY [age] DESC OFFSET @p2 ROWS FETCH NEXT @p3 ROWS ONLY;",
+				Args: []any{18, 10, 20},
+			},
+		},
+		{
+			name: "insert",
+			q:    NewInserter[TestModel](db).Columns("Id", "FirstName").Values(&TestModel{Id: 1, FirstName: "Deng"}),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO [test_model]([id],[first_name]) VALUES(@p1,@p2);",
+				Args: []any{int64(1), "Deng"},
+			},
+		},
+		{
+			name: "upsert",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName", "Age").Values(
+				&TestModel{Id: 1, FirstName: "Deng", Age: 18},
+				&TestModel{Id: 2, FirstName: "Da", Age: 19}).
+				OnConflictKey().ConflictColumns("Id").
+				Update(C("FirstName"), Assign("Age", 20)),
+			wantQuery: &Query{
+				SQL: "MERGE INTO [test_model] AS [target] USING (VALUES(@p1,@p2,@p3),(@p4,@p5,@p6)) " +
+					"AS [excluded]([id],[first_name],[age]) ON [target].[id] = [excluded].[id] " +
+					"WHEN MATCHED THEN UPDATE SET [first_name] = [excluded].[first_name],[age] = @p7 " +
+					"WHEN NOT MATCHED THEN INSERT([id],[first_name],[age]) " +
+					"VALUES([excluded].[id],[excluded].[first_name],[excluded].[age]);",
+				Args: []any{int64(1), "Deng", int8(18), int64(2), "Da", int8(19), 20},
+			},
+		},
+		{
+			name: "upsert without conflict columns",
+			q: NewInserter[TestModel](db).Values(&TestModel{}).
+				OnConflictKey().Update(C("FirstName")),
+			wantErr: errs.ErrNoConflictColumns,
+		},
+		{
+			name: "upsert where",
+			q: NewInserter[TestModel](db).Values(&TestModel{}).
+				OnConflictKey().ConflictColumns("Id").Where(C("Age").GT(1)).Update(C("Age")),
+			wantErr: errs.ErrUnsupportedUpsertWhere,
+		},
+		{
+			// 目标表的列需要限定，否则和待插入数据的列有歧义
+			name: "upsert excluded expression",
+			q: NewInserter[TestModel](db).Columns("Id", "Age").
+				Values(&TestModel{Id: 1, Age: 18}).
+				OnConflictKey().ConflictColumns("Id").
+				Update(Assign("Age", C("Age").Add(Excluded("Age")))),
+			wantQuery: &Query{
+				SQL: "MERGE INTO [test_model] AS [target] USING (VALUES(@p1,@p2)) " +
+					"AS [excluded]([id],[age]) ON [target].[id] = [excluded].[id] " +
+					"WHEN MATCHED THEN UPDATE SET [age] = [target].[age] + [excluded].[age] " +
+					"WHEN NOT MATCHED THEN INSERT([id],[age]) VALUES([excluded].[id],[excluded].[age]);",
+				Args: []any{int64(1), int8(18)},
+			},
+		},
+		{
+			name: "do nothing from select",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).Where(C("Age").GT(18))).
+				OnConflictKey().ConflictColumns("Id").DoNothing(),
+			wantQuery: &Query{
+				SQL: "MERGE INTO [test_model] AS [target] USING (SELECT [id],[first_name] FROM [test_model] WHERE [age] > @p1) " +
+					"AS [excluded]([id],[first_name]) ON [target].[id] = [excluded].[id] " +
+					"WHEN NOT MATCHED THEN INSERT([id],[first_name]) " +
+					"VALUES([excluded].[id],[excluded].[first_name]);",
+				Args: []any{18},
+			},
+		},
+		{
+			name:    "returning",
+			q:       NewInserter[TestModel](db).Values(&TestModel{}).Returning("Id"),
+			wantErr: errs.ErrUnsupportedReturning,
+		},
+		{
+			name: "update",
+			q: NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).
+				Set(C("Age")).Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE [test_model] SET [age] = @p1 WHERE [id] = @p2;",
+				Args: []any{int8(18), 1},
+			},
+		},
+		{
+			name: "delete",
+			q:    NewDeleter[TestModel](db).Where(C("Id").EQ(16)),
+			wantQuery: &Query{
+				SQL:  "DELETE FROM [test_model] WHERE [id] = @p1;",
+				Args: []any{16},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestInserter_Returning(t *testing.T) {
+	testCases := []struct {
+		name      string
+		db        *DB
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "mysql",
+			db:      memoryDB(t, DBWithDialect(MySQL)),
+			wantErr: errs.ErrUnsupportedReturning,
+		},
+		{
+			name: "sqlite3",
+			db:   memoryDB(t, DBWithDialect(SQLite3)),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`first_name`) VALUES(?) RETURNING `id`;",
+				Args: []any{"Deng"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := NewInserter[TestModel](tc.db).Columns("FirstName").
+				Values(&TestModel{FirstName: "Deng"}).Returning("Id").Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+// proxyDialect 模拟用户自定义的方言，只使用了暴露出去的 API
+type proxyDialect struct {
+	StandardSQL
+}
+
+func (p *proxyDialect) Quoter() (byte, byte) {
+	return '`', '`'
+}
+
+func (p *proxyDialect) Placeholder(index int) string {
+	return ":" + strconv.Itoa(index)
+}
+
+func (p *proxyDialect) ColTypeOf(typ reflect.Value) string {
+	return "String"
+}
+
+// BuildLimitOffset 使用 LIMIT offset, limit 的写法
+func (p *proxyDialect) BuildLimitOffset(b *Builder, limit, offset int, ordered bool) error {
+	b.WriteString(" LIMIT ")
+	b.WritePlaceholder()
+	b.AddArgs(offset)
+	b.WriteString(", ")
+	b.WritePlaceholder()
+	b.AddArgs(limit)
+	return nil
+}
+
+func (p *proxyDialect) BuildUpsert(b *Builder, u *Upsert) error {
+	b.BuildInsert(u)
+	b.WriteString(" ON DUPLICATE KEY UPDATE ")
+	for i, assign := range u.OnConflict.Assigns() {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		switch a := assign.(type) {
+		case Assignment:
+			if err := b.BuildAssignment(a); err != nil {
+				return err
+			}
+		case Column:
+			colName, err := b.ColumnName(a)
+			if err != nil {
+				return err
+			}
+			b.Quote(colName)
+			b.WriteString(" = VALUES(")
+			b.Quote(colName)
+			_ = b.WriteByte(')')
+		}
+	}
+	return nil
+}
+
+func TestRegisterDialect(t *testing.T) {
+	assert.Panics(t, func() {
+		RegisterDialect("nil_dialect", nil)
+	})
+
+	mockDB, _, err := sqlmock.New()
+	require.NoError(t, err)
+	defer func() { _ = mockDB.Close() }()
+	_, err = OpenDB("proxy", mockDB)
+	assert.Equal(t, errs.NewUnsupportedDriverError("proxy"), err)
+
+	RegisterDialect("proxy", &proxyDialect{})
+	db, err := OpenDB("proxy", mockDB)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "select",
+			q:    NewSelector[TestModel](db).Where(C("Age").GT(18)).Limit(10).Offset(20),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` > :1 LIMIT :2, :3;",
+				Args: []any{18, 20, 10},
+			},
+		},
+		{
+			name: "upsert",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				Values(&TestModel{Id: 1, FirstName: "Deng"}).
+				OnConflictKey().Update(C("FirstName"), Assign("Age", 18)),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`) VALUES(:1,:2) " +
+					"ON DUPLICATE KEY UPDATE `first_name` = VALUES(`first_name`),`age` = :3;",
+				Args: []any{int64(1), "Deng", 18},
+			},
+		},
+		{
+			name: "upsert invalid column",
+			q: NewInserter[TestModel](db).Values(&TestModel{}).
+				OnConflictKey().Update(C("Invalid")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
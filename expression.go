@@ -2,19 +2,28 @@ package orm
 
 // 后面可以每次支持新的操作符就加一个
 const (
-	opEQ     = "="
-	opLT     = "<"
-	opGT     = ">"
-	opAND    = "AND"
-	opOR     = "OR"
-	opNOT    = "NOT"
-	opAdd    = "+"
-	opMulti  = "*"
-	opIN     = "IN"
-	opExists = "EXIST"
-	preALL   = "ALL"
-	preAny   = "ANY"
-	preSome  = "SOME"
+	opEQ        = "="
+	opNEQ       = "!="
+	opLT        = "<"
+	opLTE       = "<="
+	opGT        = ">"
+	opGTE       = ">="
+	opAND       = "AND"
+	opOR        = "OR"
+	opNOT       = "NOT"
+	opAdd       = "+"
+	opMulti     = "*"
+	opIN        = "IN"
+	opNotIN     = "NOT IN"
+	opLike      = "LIKE"
+	opNotLike   = "NOT LIKE"
+	opBetween   = "BETWEEN"
+	opIsNull    = "IS NULL"
+	opIsNotNull = "IS NOT NULL"
+	opExists    = "EXIST"
+	preALL      = "ALL"
+	preAny      = "ANY"
+	preSome     = "SOME"
 )
 
 type MathExpr binaryExpr
@@ -37,6 +46,59 @@ func (m MathExpr) Multi(val any) MathExpr {
 	}
 }
 
+// EQ 例如 C("Age").Add(1).EQ(18)
+func (m MathExpr) EQ(arg any) Predicate {
+	return newPredicate(m, opEQ, arg)
+}
+
+func (m MathExpr) NEQ(arg any) Predicate {
+	return newPredicate(m, opNEQ, arg)
+}
+
+func (m MathExpr) LT(arg any) Predicate {
+	return newPredicate(m, opLT, arg)
+}
+
+func (m MathExpr) LTE(arg any) Predicate {
+	return newPredicate(m, opLTE, arg)
+}
+
+func (m MathExpr) GT(arg any) Predicate {
+	return newPredicate(m, opGT, arg)
+}
+
+func (m MathExpr) GTE(arg any) Predicate {
+	return newPredicate(m, opGTE, arg)
+}
+
+func (m MathExpr) Like(pattern any) Predicate {
+	return newPredicate(m, opLike, pattern)
+}
+
+func (m MathExpr) NotLike(pattern any) Predicate {
+	return newPredicate(m, opNotLike, pattern)
+}
+
+func (m MathExpr) Between(lo, hi any) Predicate {
+	return newBetween(m, lo, hi)
+}
+
+func (m MathExpr) In(vals ...any) Predicate {
+	return newIn(m, opIN, vals)
+}
+
+func (m MathExpr) NotIn(vals ...any) Predicate {
+	return newIn(m, opNotIN, vals)
+}
+
+func (m MathExpr) IsNull() Predicate {
+	return newNullPredicate(m, opIsNull)
+}
+
+func (m MathExpr) IsNotNull() Predicate {
+	return newNullPredicate(m, opIsNotNull)
+}
+
 // RawExpr 代表一个原生表达式
 // 意味着 ORM 不会对它进行任何处理
 type RawExpr struct {
@@ -98,6 +160,13 @@ func exprOf(e any) Expression {
 	}
 }
 
+// inValues 是 IN 后面的值列表，构造成 (?,?,?)
+type inValues struct {
+	vals []any
+}
+
+func (i inValues) expr() {}
+
 // SubqueryExpr 注意，这个谓词这种不是在所有的数据库里面都支持的
 // 这里采取的是和 Upsert 不同的做法
 // Upsert 里面我们是属于用 dialect 来区别不同的实现
@@ -0,0 +1,167 @@
+package orm
+
+// 这些函数在不同的数据库里面名字或者参数不一样，
+// 所以交给 Dialect.BuildFunc 来构造
+const (
+	funcLower      = "LOWER"
+	funcUpper      = "UPPER"
+	funcCoalesce   = "COALESCE"
+	funcNow        = "NOW"
+	funcDateFormat = "DATE_FORMAT"
+	funcConcat     = "CONCAT"
+	funcLength     = "LENGTH"
+)
+
+// FuncExpr 代表一个 SQL 函数调用，例如 LOWER(`first_name`)。
+// 它可以用在 Select、Where、Having、OrderBy、GroupBy 和 Assign 里面，
+// 参数里面的列同样会经过字段名校验
+type FuncExpr struct {
+	name  string
+	args  []Expression
+	alias string
+	// builtin 是通过 Lower 这种辅助方法创建的，
+	// 需要方言来决定具体的写法
+	builtin bool
+}
+
+// Func 创建一个函数调用，函数名会原样输出，例如
+// Func("IFNULL", C("Age"), Raw("0"))
+func Func(name string, args ...Expression) FuncExpr {
+	return FuncExpr{
+		name: name,
+		args: args,
+	}
+}
+
+func Lower(arg Expression) FuncExpr {
+	return builtinFunc(funcLower, arg)
+}
+
+func Upper(arg Expression) FuncExpr {
+	return builtinFunc(funcUpper, arg)
+}
+
+// Coalesce 返回第一个不为 NULL 的参数，不是 Expression 的参数会作为参数值，例如
+// Coalesce(C("NickName"), "anonymous")
+func Coalesce(args ...any) FuncExpr {
+	return builtinFunc(funcCoalesce, exprsOf(args)...)
+}
+
+// Now 当前时间
+func Now() FuncExpr {
+	return builtinFunc(funcNow)
+}
+
+// DateFormat 按照 layout 格式化时间，layout 使用 Go 的写法，例如 "2006-01-02 15:04:05"，
+// 目前支持年 2006、月 01、日 02、时 15、分 04、秒 05，会被转换成对应数据库的格式
+func DateFormat(arg Expression, layout string) FuncExpr {
+	return builtinFunc(funcDateFormat, arg, value{val: layout})
+}
+
+// Concat 拼接字符串，不是 Expression 的参数会作为参数值。
+// 注意 NULL 的处理在不同数据库里面不一样
+func Concat(args ...any) FuncExpr {
+	return builtinFunc(funcConcat, exprsOf(args)...)
+}
+
+// Length 字符串的字符数，而不是字节数
+func Length(arg Expression) FuncExpr {
+	return builtinFunc(funcLength, arg)
+}
+
+func builtinFunc(name string, args ...Expression) FuncExpr {
+	return FuncExpr{
+		name:    name,
+		args:    args,
+		builtin: true,
+	}
+}
+
+func exprsOf(args []any) []Expression {
+	res := make([]Expression, 0, len(args))
+	for _, arg := range args {
+		res = append(res, exprOf(arg))
+	}
+	return res
+}
+
+func (f FuncExpr) expr() {}
+
+func (f FuncExpr) selectedAlias() string {
+	return f.alias
+}
+
+func (f FuncExpr) fieldName() string {
+	return ""
+}
+
+func (f FuncExpr) target() TableReference {
+	return nil
+}
+
+// Name 函数名，通过辅助方法创建的函数是大写的名字，例如 DATE_FORMAT
+func (f FuncExpr) Name() string {
+	return f.name
+}
+
+// Args 函数的参数
+func (f FuncExpr) Args() []Expression {
+	return f.args
+}
+
+func (f FuncExpr) As(alias string) FuncExpr {
+	f.alias = alias
+	return f
+}
+
+func (f FuncExpr) EQ(arg any) Predicate {
+	return newPredicate(f, opEQ, arg)
+}
+
+func (f FuncExpr) NEQ(arg any) Predicate {
+	return newPredicate(f, opNEQ, arg)
+}
+
+func (f FuncExpr) LT(arg any) Predicate {
+	return newPredicate(f, opLT, arg)
+}
+
+func (f FuncExpr) LTE(arg any) Predicate {
+	return newPredicate(f, opLTE, arg)
+}
+
+func (f FuncExpr) GT(arg any) Predicate {
+	return newPredicate(f, opGT, arg)
+}
+
+func (f FuncExpr) GTE(arg any) Predicate {
+	return newPredicate(f, opGTE, arg)
+}
+
+func (f FuncExpr) Like(pattern any) Predicate {
+	return newPredicate(f, opLike, pattern)
+}
+
+func (f FuncExpr) NotLike(pattern any) Predicate {
+	return newPredicate(f, opNotLike, pattern)
+}
+
+func (f FuncExpr) Between(lo, hi any) Predicate {
+	return newBetween(f, lo, hi)
+}
+
+func (f FuncExpr) In(vals ...any) Predicate {
+	return newIn(f, opIN, vals)
+}
+
+func (f FuncExpr) NotIn(vals ...any) Predicate {
+	return newIn(f, opNotIN, vals)
+}
+
+func (f FuncExpr) IsNull() Predicate {
+	return newNullPredicate(f, opIsNull)
+}
+
+func (f FuncExpr) IsNotNull() Predicate {
+	return newNullPredicate(f, opIsNotNull)
+}
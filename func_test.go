@@ -0,0 +1,218 @@
+package orm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"orm/internal/errs"
+	"testing"
+)
+
+func TestFunc_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "select",
+			q: NewSelector[TestModel](db).
+				Select(Upper(C("FirstName")).As("name"), Func("IFNULL", C("Age"), Raw("0"))),
+			wantQuery: &Query{
+				SQL: "SELECT UPPER(`first_name`) AS `name`,IFNULL(`age`,0) FROM `test_model`;",
+			},
+		},
+		{
+			name: "where",
+			q: NewSelector[TestModel](db).
+				Where(Lower(C("FirstName")).EQ("tom"), Length(C("FirstName")).GT(3)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (LOWER(`first_name`) = ?) AND (LENGTH(`first_name`) > ?);",
+				Args: []any{"tom", 3},
+			},
+		},
+		{
+			name: "coalesce and concat",
+			q: NewSelector[TestModel](db).
+				Select(Concat(C("FirstName"), " ", Coalesce(C("LastName"), "-")).As("full_name")),
+			wantQuery: &Query{
+				SQL:  "SELECT (`first_name` || ? || COALESCE(`last_name`,?)) AS `full_name` FROM `test_model`;",
+				Args: []any{" ", "-"},
+			},
+		},
+		{
+			name: "group by and order by",
+			q: NewSelector[TestModel](db).
+				Select(DateFormat(C("Age"), "2006-01-02 15:04:05").As("day"), Count("Id")).
+				GroupBy(DateFormat(C("Age"), "2006-01-02 15:04:05")).
+				OrderBy(Desc(Lower(C("FirstName"))), Asc("Id")),
+			wantQuery: &Query{
+				SQL: "SELECT strftime(?,`age`) AS `day`,COUNT(`id`) FROM `test_model` " +
+					"GROUP BY strftime(?,`age`) ORDER BY LOWER(`first_name`) DESC,`id` ASC;",
+				Args: []any{"%Y-%m-%d %H:%M:%S", "%Y-%m-%d %H:%M:%S"},
+			},
+		},
+		{
+			name: "update",
+			q: NewUpdater[TestModel](db).
+				Set(Assign("FirstName", Upper(C("FirstName")))).
+				Where(C("Id").EQ(1)),
+			wantQuery: &Query{
+				SQL:  "UPDATE `test_model` SET `first_name` = UPPER(`first_name`) WHERE `id` = ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "now",
+			q:    NewSelector[TestModel](db).Select(Now().As("now")),
+			wantQuery: &Query{
+				SQL: "SELECT CURRENT_TIMESTAMP AS `now` FROM `test_model`;",
+			},
+		},
+		{
+			name:    "invalid column",
+			q:       NewSelector[TestModel](db).Where(Lower(C("Invalid")).EQ("tom")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "invalid order by column",
+			q:       NewSelector[TestModel](db).OrderBy(Asc(Upper(C("Invalid")))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestFunc_Dialect(t *testing.T) {
+	fns := []Selectable{
+		Now().As("now"),
+		Length(C("FirstName")).As("len"),
+		Concat(C("FirstName"), C("LastName")).As("name"),
+		DateFormat(C("Age"), "2006/01/02 15:04").As("day"),
+	}
+	testCases := []struct {
+		name      string
+		dialect   Dialect
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "mysql",
+			dialect: MySQL,
+			wantQuery: &Query{
+				SQL: "SELECT NOW() AS `now`,CHAR_LENGTH(`first_name`) AS `len`," +
+					"CONCAT(`first_name`,`last_name`) AS `name`,DATE_FORMAT(`age`,?) AS `day` FROM `test_model`;",
+				Args: []any{"%Y/%m/%d %H:%i"},
+			},
+		},
+		{
+			name:    "sqlite",
+			dialect: SQLite3,
+			wantQuery: &Query{
+				SQL: "SELECT CURRENT_TIMESTAMP AS `now`,LENGTH(`first_name`) AS `len`," +
+					"(`first_name` || `last_name`) AS `name`,strftime(?,`age`) AS `day` FROM `test_model`;",
+				Args: []any{"%Y/%m/%d %H:%M"},
+			},
+		},
+		{
+			name:    "postgres",
+			dialect: Postgres,
+			wantQuery: &Query{
+				SQL: `SELECT NOW() AS "now",CHAR_LENGTH("first_name") AS "len",` +
+					`("first_name" || "last_name") AS "name",TO_CHAR("age",$1) AS "day" FROM "test_model";`,
+				Args: []any{"YYYY/MM/DD HH24:MI"},
+			},
+		},
+		{
+			name:    "mssql",
+			dialect: MSSQL,
+			wantQuery: &Query{
+				SQL: "SELECT CURRENT_TIMESTAMP AS [now],LEN([first_name]) AS [len]," +
+					"CONCAT([first_name],[last_name]) AS [name],FORMAT([age],@p1) AS [day] FROM [test_model];",
+				Args: []any{"yyyy/MM/dd HH:mm"},
+			},
+		},
+		{
+			name:    "standard sql",
+			dialect: &proxyDialect{},
+			wantErr: errs.NewErrUnsupportedFunc(funcDateFormat),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := memoryDB(t, DBWithDialect(tc.dialect))
+			q, err := NewSelector[TestModel](db).Select(fns...).Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestFunc_DateFormatLayout(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(MySQL))
+	testCases := []struct {
+		name    string
+		fn      FuncExpr
+		wantErr error
+	}{
+		{
+			// layout 只能是字符串
+			name:    "column layout",
+			fn:      builtinFunc(funcDateFormat, C("Age"), C("FirstName")),
+			wantErr: errs.NewErrUnsupportedExpressionType(C("FirstName")),
+		},
+		{
+			name:    "raw layout",
+			fn:      builtinFunc(funcDateFormat, C("Age"), Raw("'%Y'")),
+			wantErr: errs.NewErrUnsupportedExpressionType(Raw("'%Y'")),
+		},
+		{
+			name:    "missing layout",
+			fn:      builtinFunc(funcDateFormat, C("Age")),
+			wantErr: errs.NewErrUnsupportedExpressionType(builtinFunc(funcDateFormat, C("Age"))),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewSelector[TestModel](db).Select(tc.fn).Build()
+			assert.Equal(t, tc.wantErr, err)
+		})
+	}
+}
+
+func TestFunc_Exec(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("func", t)
+	require.NoError(t, NewCreater[TestModel](db).Exec(ctx).Err())
+	for _, val := range []*TestModel{
+		{Id: 1, FirstName: "Tom", Age: 18},
+		{Id: 2, FirstName: "Jerry", Age: 20},
+	} {
+		require.NoError(t, NewInserter[TestModel](db).Values(val).Exec(ctx).Err())
+	}
+	require.NoError(t, NewUpdater[TestModel](db).
+		Set(Assign("FirstName", Upper(C("FirstName")))).
+		Where(C("Id").EQ(1)).Exec(ctx).Err())
+
+	res, err := NewSelector[TestModel](db).
+		Where(Lower(C("FirstName")).In("tom", "jerry")).
+		OrderBy(Desc(Length(C("FirstName")))).GetMulti(ctx)
+	require.NoError(t, err)
+	require.Len(t, res, 2)
+	assert.Equal(t, "Jerry", res[0].FirstName)
+	assert.Equal(t, "TOM", res[1].FirstName)
+}
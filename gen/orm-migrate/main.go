@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/mattn/go-sqlite3"
+	"io"
+	"orm"
+	"orm/migrate"
+	"os"
+	"strconv"
+)
+
+// 用法：
+// orm-migrate -driver mysql -dsn "root:root@tcp(localhost:3306)/test?multiStatements=true" -dir ./migrations up
+// 支持的命令有 up, down [n], goto <version>, status
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+var errUsage = errors.New("用法: orm-migrate -driver <driver> -dsn <dsn> -dir <dir> up | down [n] | goto <version> | status")
+
+func run(args []string, out io.Writer) error {
+	fs := flag.NewFlagSet("orm-migrate", flag.ContinueOnError)
+	fs.SetOutput(out)
+	driver := fs.String("driver", "mysql", "数据库驱动，例如 mysql, sqlite3")
+	dsn := fs.String("dsn", "", "数据库连接")
+	dir := fs.String("dir", "migrations", "迁移文件所在的目录")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 || *dsn == "" {
+		return errUsage
+	}
+
+	db, err := orm.Open(*driver, *dsn)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := migrate.New(db, os.DirFS(*dir))
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	switch cmd := fs.Arg(0); cmd {
+	case "up":
+		err = m.Up(ctx)
+	case "down":
+		n := 1
+		if fs.NArg() > 1 {
+			if n, err = strconv.Atoi(fs.Arg(1)); err != nil {
+				return errUsage
+			}
+		}
+		err = m.Down(ctx, n)
+	case "goto":
+		if fs.NArg() < 2 {
+			return errUsage
+		}
+		var version int64
+		if version, err = strconv.ParseInt(fs.Arg(1), 10, 64); err != nil {
+			return errUsage
+		}
+		err = m.Goto(ctx, version)
+	case "status":
+		return printStatus(ctx, m, out)
+	default:
+		return errUsage
+	}
+	if err != nil {
+		return err
+	}
+	return printStatus(ctx, m, out)
+}
+
+func printStatus(ctx context.Context, m *migrate.Migrator, out io.Writer) error {
+	sts, err := m.Status(ctx)
+	if err != nil {
+		return err
+	}
+	for _, st := range sts {
+		appliedAt := "pending"
+		if st.Applied {
+			appliedAt = st.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		_, err = fmt.Fprintf(out, "%d\t%s\t%s\n", st.Version, st.Name, appliedAt)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"regexp"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	// 每次 run 都会关闭连接，所以不能使用内存数据库
+	dsn := filepath.Join(t.TempDir(), "orm_migrate.db")
+	base := []string{"-driver", "sqlite3", "-dsn", dsn, "-dir", "testdata"}
+	testCases := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr error
+	}{
+		{
+			name: "status",
+			args: []string{"status"},
+			want: "1\tcreate_user\tpending\n2\tadd_age\tpending\n",
+		},
+		{
+			name: "up",
+			args: []string{"up"},
+			want: "1\tcreate_user\tapplied\n2\tadd_age\tapplied\n",
+		},
+		{
+			name: "down",
+			args: []string{"down"},
+			want: "1\tcreate_user\tapplied\n2\tadd_age\tpending\n",
+		},
+		{
+			name: "goto",
+			args: []string{"goto", "0"},
+			want: "1\tcreate_user\tpending\n2\tadd_age\tpending\n",
+		},
+		{
+			name:    "unknown command",
+			args:    []string{"redo"},
+			wantErr: errUsage,
+		},
+		{
+			name:    "invalid version",
+			args:    []string{"goto", "abc"},
+			wantErr: errUsage,
+		},
+	}
+	appliedAt := regexp.MustCompile(`\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`)
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err := run(append(base, tc.args...), out)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, appliedAt.ReplaceAllString(out.String(), "applied"))
+		})
+	}
+}
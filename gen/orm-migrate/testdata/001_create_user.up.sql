@@ -0,0 +1 @@
+CREATE TABLE `user`(`id` integer PRIMARY KEY);
@@ -2,19 +2,112 @@ package orm
 
 import (
 	"context"
+	"database/sql"
 	"github.com/valyala/bytebufferpool"
 	"orm/internal/errs"
 	"orm/model"
+	"reflect"
 )
 
 type OnConflictBuilder[T any] struct {
 	i               *Inserter[T]
 	conflictColumns []string
+	where           []Predicate
 }
 
 type OnConflict struct {
 	assigns         []Assignable
 	conflictColumns []string
+	// doNothing 冲突的时候什么也不做
+	doNothing bool
+	// where 只有满足条件的时候才更新
+	where []Predicate
+}
+
+// Assigns 冲突时需要更新的部分，
+// 元素是 Assignment 或者 Column，Column 代表使用待插入的值
+func (o *OnConflict) Assigns() []Assignable {
+	return o.assigns
+}
+
+// ConflictColumns 冲突列，是字段名而不是列名
+func (o *OnConflict) ConflictColumns() []string {
+	return o.conflictColumns
+}
+
+// DoNothing 冲突的时候是否忽略这一行，为 true 的时候 Assigns 为空
+func (o *OnConflict) DoNothing() bool {
+	return o.doNothing
+}
+
+// Where 更新的条件，为空的时候总是更新
+func (o *OnConflict) Where() []Predicate {
+	return o.where
+}
+
+// ExcludedExpr 代表 upsert 中待插入的值，通过 Excluded 创建
+type ExcludedExpr struct {
+	name string
+}
+
+// Excluded 引用 upsert 中字段 name 待插入的值，只能在冲突时的更新部分和条件里面使用，
+// 例如 Assign("Counter", C("Counter").Add(Excluded("Counter")))。
+// SQLite 和 PostgreSQL 对应 excluded.col，MySQL 对应 VALUES(col) 或者行别名
+func Excluded(name string) ExcludedExpr {
+	return ExcludedExpr{name: name}
+}
+
+func (ExcludedExpr) expr() {}
+
+func (e ExcludedExpr) Add(val any) MathExpr {
+	return MathExpr{
+		left:  e,
+		op:    opAdd,
+		right: valueOf(val),
+	}
+}
+
+func (e ExcludedExpr) Multi(val any) MathExpr {
+	return MathExpr{
+		left:  e,
+		op:    opMulti,
+		right: valueOf(val),
+	}
+}
+
+func (e ExcludedExpr) EQ(arg any) Predicate {
+	return newPredicate(e, opEQ, arg)
+}
+
+func (e ExcludedExpr) NEQ(arg any) Predicate {
+	return newPredicate(e, opNEQ, arg)
+}
+
+func (e ExcludedExpr) LT(arg any) Predicate {
+	return newPredicate(e, opLT, arg)
+}
+
+func (e ExcludedExpr) LTE(arg any) Predicate {
+	return newPredicate(e, opLTE, arg)
+}
+
+func (e ExcludedExpr) GT(arg any) Predicate {
+	return newPredicate(e, opGT, arg)
+}
+
+func (e ExcludedExpr) GTE(arg any) Predicate {
+	return newPredicate(e, opGTE, arg)
+}
+
+// Upsert 构造 upsert 语句所需要的全部信息，交给 Dialect 构造完整的语句
+type Upsert struct {
+	Table  string
+	Fields []*model.Field
+	// Rows 每一行待插入的值，顺序和 Fields 一致
+	Rows [][]any
+	// Select 不为 nil 的时候插入的是它查询出来的数据，此时 Rows 为空
+	Select     QueryBuilder
+	OnConflict *OnConflict
 }
 
 type Inserter[T any] struct {
@@ -22,8 +115,19 @@ type Inserter[T any] struct {
 	sess    session
 	values  []*T
 	columns []string
+	// from INSERT ... SELECT 的数据来源
+	from QueryBuilder
 	// 方案二
 	onConflict *OnConflict
+	// returning 需要通过 RETURNING 返回的列
+	returning []string
+	// autoID 执行之后需要回写到 values 里面的自增主键，
+	// 只有自增主键没有被插入的时候才会回写
+	autoID *model.Field
+	// returningID 是否通过 RETURNING 拿到自增主键
+	returningID bool
+	// batchSize 每条语句最多插入的行数，小于等于 0 的时候根据 Dialect.MaxArgs 计算
+	batchSize int
 
 	// 方案一
 	// onDuplicate []Assignable
@@ -37,7 +141,6 @@ func NewInserter[T any](sess session) *Inserter[T] {
 			core:     c,
 			buffer:   bytebufferpool.Get(),
 			aliasMap: make(map[string]int, 8),
-			quoter:   c.dialect.quoter(),
 		},
 	}
 }
@@ -59,24 +162,68 @@ func (i *Inserter[T]) Values(vals ...*T) *Inserter[T] {
 	return i
 }
 
+// FromSelect 插入 q 查询出来的数据，即 INSERT INTO ... SELECT ...。
+// q 的列要和 Columns 指定的列一一对应，没有指定 Columns 的时候对应所有的列。
+// 不能和 Values 一起使用
+func (i *Inserter[T]) FromSelect(q QueryBuilder) *Inserter[T] {
+	i.from = q
+	return i
+}
+
+// BatchSize 指定 Exec 的时候每条语句最多插入多少行，超过的部分会拆成多条语句。
+// 不指定的时候根据方言支持的最大参数个数计算。
+// 注意 MySQL 的语句还受 max_allowed_packet 限制，行比较大的时候需要自己指定
+func (i *Inserter[T]) BatchSize(n int) *Inserter[T] {
+	i.batchSize = n
+	return i
+}
+
+// Returning 指定 RETURNING 子句返回的列，
+// 只有 PostgreSQL 和 SQLite 这一类支持 RETURNING 的方言可以使用
+func (i *Inserter[T]) Returning(cols ...string) *Inserter[T] {
+	i.returning = cols
+	return i
+}
+
 func (o *OnConflictBuilder[T]) ConflictColumns(cols ...string) *OnConflictBuilder[T] {
 	o.conflictColumns = cols
 	return o
 }
 
+// DoNothing 冲突的时候忽略这一行。
+// MySQL 使用 INSERT IGNORE，此时冲突列没有意义，并且其它错误也会被忽略
+func (o *OnConflictBuilder[T]) DoNothing() *Inserter[T] {
+	o.i.onConflict = &OnConflict{
+		conflictColumns: o.conflictColumns,
+		doNothing:       true,
+	}
+	return o.i
+}
+
+// Where 只有满足条件的时候才更新，例如只在待插入的版本更新的时候才覆盖。
+// 只有 SQLite 和 PostgreSQL 支持
+func (o *OnConflictBuilder[T]) Where(ps ...Predicate) *OnConflictBuilder[T] {
+	o.where = ps
+	return o
+}
+
 func (o *OnConflictBuilder[T]) Update(assigns ...Assignable) *Inserter[T] {
 	o.i.onConflict = &OnConflict{
 		assigns:         assigns,
 		conflictColumns: o.conflictColumns,
+		where:           o.where,
 	}
 	return o.i
 }
 
 func (i *Inserter[T]) Build() (*Query, error) {
-	if len(i.values) == 0 {
+	if i.from != nil && len(i.values) > 0 {
+		return nil, errs.ErrInsertValuesAndSelect
+	}
+	if len(i.values) == 0 && i.from == nil {
 		return nil, errs.ErrInsertZeroRow
 	}
-	defer bytebufferpool.Put(i.buffer)
+	defer i.release()
 	var (
 		t   T
 		err error
@@ -87,9 +234,6 @@ func (i *Inserter[T]) Build() (*Query, error) {
 			return nil, err
 		}
 	}
-	i.writeString("INSERT INTO ")
-	i.quote(i.model.TableName)
-
 	fields := i.model.Fields
 	if len(i.columns) > 0 {
 		fields = make([]*model.Field, 0, len(i.columns))
@@ -100,45 +244,62 @@ func (i *Inserter[T]) Build() (*Query, error) {
 			}
 			fields = append(fields, fd)
 		}
-	}
-
-	i.writeLeftParenthesis()
-	for idx, fd := range fields {
-		if idx > 0 {
-			i.writeComma()
+	} else if pk := autoIncrementPK(i.model); pk != nil && i.from == nil {
+		withPK, withoutPK := i.splitByPK(pk, i.values)
+		if len(withPK) > 0 && len(withoutPK) > 0 {
+			// 零值会被当成主键插入，Exec 会把它们拆成两条语句
+			return nil, errs.ErrMixedAutoIncrementPK
 		}
-		i.quote(fd.ColName)
+		if len(withPK) == 0 {
+			// 自增主键都是零值的时候不插入，交给数据库生成
+			fields = withoutField(fields, pk)
+		}
+	}
+	i.autoID = nil
+	i.returningID = false
+	if pk := autoIncrementPK(i.model); pk != nil && i.from == nil && !containsField(fields, pk) {
+		i.autoID = pk
 	}
-	i.writeRightParenthesis()
 
-	i.writeSpace()
-	i.writeString("VALUES")
-	i.args = make([]any, 0, len(fields)*len(i.values)+1)
-	for vIdx, val := range i.values {
-		if vIdx > 0 {
-			i.writeComma()
-		}
+	rows := make([][]any, 0, len(i.values))
+	for _, val := range i.values {
 		refVal := i.valCreator.NewBasicTypeValue(val, i.model)
-		i.writeLeftParenthesis()
-		for fIdx, fd := range fields {
-			if fIdx > 0 {
-				i.writeComma()
-			}
-			i.writePlaceholder()
+		row := make([]any, 0, len(fields))
+		for _, fd := range fields {
 			fdVal, err := refVal.Field(fd.GoName)
 			if err != nil {
 				return nil, err
 			}
-			i.addArgs(fdVal)
+			row = append(row, fdVal)
 		}
-		i.writeRightParenthesis()
+		rows = append(rows, row)
 	}
-	if i.onConflict != nil {
-		err = i.dialect.buildOnConflict(&i.Builder, i.onConflict)
 
-		if err != nil {
+	i.args = make([]any, 0, len(fields)*len(i.values)+1)
+	u := &Upsert{
+		Table:      i.model.TableName,
+		Fields:     fields,
+		Rows:       rows,
+		Select:     i.from,
+		OnConflict: i.onConflict,
+	}
+	if i.onConflict != nil {
+		i.upsert = u
+		err = i.dialect.BuildUpsert(&i.Builder, u)
+	} else {
+		err = i.buildInsert("INSERT", u)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(i.returning) > 0 {
+		if err = i.buildReturning(); err != nil {
 			return nil, err
 		}
+	} else if i.autoID != nil && i.dialect.SupportReturning() {
+		i.writeString(" RETURNING ")
+		i.quote(i.autoID.ColName)
+		i.returningID = true
 	}
 	i.end()
 	return &Query{
@@ -147,6 +308,97 @@ func (i *Inserter[T]) Build() (*Query, error) {
 	}, nil
 }
 
+// buildInsert 构造 INSERT INTO table(col1,col2) VALUES(?,?),(?,?) 部分，
+// keyword 是 INSERT 或者 INSERT IGNORE 这种 INTO 之前的部分
+func (b *Builder) buildInsert(keyword string, u *Upsert) error {
+	b.writeString(keyword)
+	b.writeString(" INTO ")
+	b.quote(u.Table)
+	b.buildFieldList(u.Fields)
+	b.writeSpace()
+	return b.buildInsertSource(u)
+}
+
+// buildInsertSource 构造 VALUES(?,?),(?,?) 或者 SELECT ... 部分
+func (b *Builder) buildInsertSource(u *Upsert) error {
+	if u.Select == nil {
+		b.writeString("VALUES")
+		b.buildValueRows(u.Rows)
+		return nil
+	}
+	if setter, ok := u.Select.(argsOffsetSetter); ok {
+		setter.setArgsOffset(b.argsOffset + len(b.args))
+	}
+	q, err := u.Select.Build()
+	if err != nil {
+		return err
+	}
+	b.writeString(trimEnd(q.SQL))
+	if len(q.Args) > 0 {
+		b.addArgs(q.Args...)
+	}
+	return nil
+}
+
+// buildFieldList 构造 (col1,col2) 部分
+func (b *Builder) buildFieldList(fields []*model.Field) {
+	b.writeLeftParenthesis()
+	for idx, fd := range fields {
+		if idx > 0 {
+			b.writeComma()
+		}
+		b.quote(fd.ColName)
+	}
+	b.writeRightParenthesis()
+}
+
+// buildValueRows 构造 (?,?),(?,?) 部分
+func (b *Builder) buildValueRows(rows [][]any) {
+	for rIdx, row := range rows {
+		if rIdx > 0 {
+			b.writeComma()
+		}
+		b.writeLeftParenthesis()
+		for vIdx, val := range row {
+			if vIdx > 0 {
+				b.writeComma()
+			}
+			b.writePlaceholder()
+			b.addArgs(val)
+		}
+		b.writeRightParenthesis()
+	}
+}
+
+func (i *Inserter[T]) buildReturning() error {
+	if !i.dialect.SupportReturning() {
+		return errs.ErrUnsupportedReturning
+	}
+	i.writeString(" RETURNING ")
+	for idx, col := range i.returning {
+		if idx > 0 {
+			i.writeComma()
+		}
+		fd, ok := i.model.FieldMap[col]
+		if !ok {
+			return errs.NewErrUnknownField(col)
+		}
+		i.quote(fd.ColName)
+	}
+	return nil
+}
+
+// Exec 执行插入语句，并且把数据库生成的自增主键回写到 values 里面。
+// 支持 RETURNING 的方言直接读取每一行返回的主键；
+// 其余方言从 LastInsertId 开始按照连续的 ID 推断，参考 DBWithSafeInsertID。
+// 使用 Returning 或者 OnConflictKey 的时候不会推断。
+//
+// 超过 BatchSize 的时候会拆成多条语句，每一条都会单独经过 Middleware。
+// 没有指定 Columns 并且只有部分行指定了自增主键的时候，
+// 指定了的行和没有指定的行会分开插入，前者在前。
+// 如果 session 是 DB，那么所有的语句在同一个事务里面执行；
+// 返回的 RowsAffected 是所有语句的和，LastInsertId 是最后一条语句的。
+// 注意执行失败的时候，之前的批次已经回写的主键不会被清除
 func (i *Inserter[T]) Exec(ctx context.Context) Result {
 	if i.model == nil {
 		m, err := i.r.Get(new(T))
@@ -156,9 +408,211 @@ func (i *Inserter[T]) Exec(ctx context.Context) Result {
 		i.model = m
 	}
 
-	return exec[T](ctx, i.core, i.sess, &QueryContext{
+	chunks := i.chunks()
+	if len(chunks) <= 1 {
+		return i.exec(ctx)
+	}
+	db, ok := i.sess.(*DB)
+	if !ok {
+		return i.execChunks(ctx, i.sess, chunks)
+	}
+	var res Result
+	err := db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		res = i.execChunks(ctx, tx, chunks)
+		return res.err
+	}, nil)
+	if err != nil {
+		return Result{err: err}
+	}
+	return res
+}
+
+// exec 用一条语句插入全部的 values
+func (i *Inserter[T]) exec(ctx context.Context) Result {
+	res := execWithHandler(ctx, i.core, &QueryContext{
 		Type:    "INSERT",
 		Builder: i,
 		Meta:    i.model,
+	}, func(ctx context.Context, qc *QueryContext) *QueryResult {
+		q, err := qc.Query()
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		if i.returningID {
+			res, err := i.queryIDs(ctx, q)
+			return &QueryResult{Result: res, Err: err}
+		}
+		res, err := i.sess.execContext(ctx, q.SQL, q.Args...)
+		return &QueryResult{Result: res, Err: err}
 	})
+	if res.err == nil && res.res != nil {
+		i.inferIDs(res.res)
+	}
+	return res
+}
+
+// execChunks 在 sess 上面依次插入每一批数据
+func (i *Inserter[T]) execChunks(ctx context.Context, sess session, chunks [][]*T) Result {
+	res := make(batchResult, 0, len(chunks))
+	for _, chunk := range chunks {
+		ci := NewInserter[T](sess).Values(chunk...)
+		ci.model = i.model
+		ci.columns = i.columns
+		ci.onConflict = i.onConflict
+		ci.returning = i.returning
+		r := ci.exec(ctx)
+		if r.err != nil {
+			return r
+		}
+		res = append(res, r.res)
+	}
+	return Result{res: res}
+}
+
+// chunks 按照 batchSize 拆分 values，
+// values 里面部分行指定了自增主键的时候，先按照是否指定了自增主键分组
+func (i *Inserter[T]) chunks() [][]*T {
+	if pk := autoIncrementPK(i.model); pk != nil && len(i.columns) == 0 && i.from == nil {
+		withPK, withoutPK := i.splitByPK(pk, i.values)
+		if len(withPK) > 0 && len(withoutPK) > 0 {
+			return append(i.chunk(withPK), i.chunk(withoutPK)...)
+		}
+	}
+	return i.chunk(i.values)
+}
+
+// chunk 按照 batchSize 拆分 vals
+func (i *Inserter[T]) chunk(vals []*T) [][]*T {
+	size := i.batchSize
+	if size <= 0 {
+		cols := len(i.columns)
+		if cols == 0 {
+			cols = len(i.model.Fields)
+		}
+		maxArgs := i.dialect.MaxArgs()
+		if i.onConflict != nil {
+			// 冲突时更新的值也会占用参数
+			maxArgs -= len(i.onConflict.assigns)
+		}
+		if cols > 0 {
+			size = maxArgs / cols
+		}
+	}
+	if size <= 0 {
+		size = 1
+	}
+	res := make([][]*T, 0, (len(vals)+size-1)/size)
+	for start := 0; start < len(vals); start += size {
+		end := start + size
+		if end > len(vals) {
+			end = len(vals)
+		}
+		res = append(res, vals[start:end])
+	}
+	return res
+}
+
+// queryIDs 执行带有 RETURNING 的插入语句，按照顺序回写自增主键
+func (i *Inserter[T]) queryIDs(ctx context.Context, q *Query) (sql.Result, error) {
+	rows, err := i.sess.queryContext(ctx, q.SQL, q.Args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	ids := make([]int64, 0, len(i.values))
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	// 行数对不上的时候无法确定每一行的主键，例如冲突之后什么都没做
+	if len(ids) == len(i.values) {
+		for idx, val := range i.values {
+			setAutoID(val, i.autoID, ids[idx])
+		}
+	}
+	return returningResult(ids), nil
+}
+
+// inferIDs 从 LastInsertId 开始按照连续的 ID 推断每一行的自增主键。
+// MySQL 的 LastInsertId 是这一批里面第一行的主键
+func (i *Inserter[T]) inferIDs(res sql.Result) {
+	if i.autoID == nil || i.returningID || i.onConflict != nil {
+		return
+	}
+	if len(i.values) > 1 && i.safeInsertID {
+		return
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return
+	}
+	affected, err := res.RowsAffected()
+	if err != nil || affected != int64(len(i.values)) {
+		return
+	}
+	for idx, val := range i.values {
+		setAutoID(val, i.autoID, id+int64(idx))
+	}
+}
+
+// splitByPK 按照主键 pk 是否是零值拆分 vals，保持原本的顺序
+func (i *Inserter[T]) splitByPK(pk *model.Field, vals []*T) (withPK, withoutPK []*T) {
+	for _, val := range vals {
+		fdVal, err := reflect.ValueOf(val).Elem().FieldByIndexErr(pk.Index)
+		if err != nil || !fdVal.IsZero() {
+			withPK = append(withPK, val)
+		} else {
+			withoutPK = append(withoutPK, val)
+		}
+	}
+	return withPK, withoutPK
+}
+
+// autoIncrementPK 返回自增主键，没有或者是组合主键的时候返回 nil
+func autoIncrementPK(m *model.Model) *model.Field {
+	if len(m.PrimaryKeys) != 1 || !m.PrimaryKeys[0].AutoIncrement {
+		return nil
+	}
+	return m.PrimaryKeys[0]
+}
+
+func containsField(fields []*model.Field, fd *model.Field) bool {
+	for _, f := range fields {
+		if f == fd {
+			return true
+		}
+	}
+	return false
+}
+
+func withoutField(fields []*model.Field, fd *model.Field) []*model.Field {
+	res := make([]*model.Field, 0, len(fields))
+	for _, f := range fields {
+		if f != fd {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// setAutoID 把 id 写入 val 的 fd 字段，字段不是整数的时候什么也不做
+func setAutoID(val any, fd *model.Field, id int64) {
+	fdVal, err := reflect.ValueOf(val).Elem().FieldByIndexErr(fd.Index)
+	if err != nil {
+		return
+	}
+	switch fdVal.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		fdVal.SetInt(id)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		fdVal.SetUint(uint64(id))
+	}
 }
@@ -3,7 +3,9 @@ package orm
 import (
 	"context"
 	"database/sql"
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"orm/internal/errs"
 	"testing"
 )
@@ -46,8 +48,279 @@ func TestInserter_Exec(t *testing.T) {
 	}
 }
 
+// AutoIdModel 自增主键的模型
+type AutoIdModel struct {
+	Id   int64 `orm:"primary_key,auto_increment"`
+	Name string
+}
+
+func TestInserter_ExecAutoID(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("auto_id", t)
+	require.NoError(t, NewCreater[AutoIdModel](db).Exec(ctx).Err())
+
+	// 主键都是零值，不插入主键，通过 RETURNING 回写
+	q, err := NewInserter[AutoIdModel](db).Values(&AutoIdModel{Name: "Tom"}).Build()
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO `auto_id_model`(`name`) VALUES(?) RETURNING `id`;", q.SQL)
+
+	// 同一个 Inserter 重复 Build，上一次的 RETURNING 状态不能带过来
+	ins := NewInserter[AutoIdModel](db).Values(&AutoIdModel{Name: "Tom"})
+	_, err = ins.Build()
+	require.NoError(t, err)
+	assert.True(t, ins.returningID)
+	q, err = ins.Returning("Name").Build()
+	require.NoError(t, err)
+	assert.Equal(t, "INSERT INTO `auto_id_model`(`name`) VALUES(?) RETURNING `name`;", q.SQL)
+	assert.False(t, ins.returningID)
+
+	// 一条语句没办法表达部分行指定了自增主键，Exec 会把它们拆开
+	_, err = NewInserter[AutoIdModel](db).Values(&AutoIdModel{Id: 10, Name: "Tom"}, &AutoIdModel{Name: "Jerry"}).Build()
+	assert.Equal(t, errs.ErrMixedAutoIncrementPK, err)
+
+	vals := []*AutoIdModel{{Name: "Tom"}, {Name: "Jerry"}, {Name: "Spike"}}
+	res := NewInserter[AutoIdModel](db).Values(vals...).Exec(ctx)
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), affected)
+	assert.Equal(t, []*AutoIdModel{
+		{Id: 1, Name: "Tom"}, {Id: 2, Name: "Jerry"}, {Id: 3, Name: "Spike"},
+	}, vals)
+
+	// 指定了主键的时候不会覆盖，没有指定的行由数据库生成
+	vals = []*AutoIdModel{{Id: 10, Name: "Tom"}, {Name: "Jerry"}}
+	res = NewInserter[AutoIdModel](db).Values(vals...).Exec(ctx)
+	require.NoError(t, res.Err())
+	affected, err = res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	assert.Equal(t, []*AutoIdModel{{Id: 10, Name: "Tom"}, {Id: 11, Name: "Jerry"}}, vals)
+	jerry, err := NewSelector[AutoIdModel](db).Where(C("Id").EQ(11)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &AutoIdModel{Id: 11, Name: "Jerry"}, jerry)
+}
+
+func TestInserter_ExecInferID(t *testing.T) {
+	testCases := []struct {
+		name    string
+		opts    []DBOption
+		vals    []*AutoIdModel
+		mock    func(mock sqlmock.Sqlmock)
+		wantVal []*AutoIdModel
+	}{
+		{
+			name: "batch",
+			vals: []*AutoIdModel{{Name: "Tom"}, {Name: "Jerry"}},
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `auto_id_model`(`name`) VALUES(?),(?);").
+					WillReturnResult(sqlmock.NewResult(11, 2))
+			},
+			wantVal: []*AutoIdModel{{Id: 11, Name: "Tom"}, {Id: 12, Name: "Jerry"}},
+		},
+		{
+			name: "rows affected mismatch",
+			vals: []*AutoIdModel{{Name: "Tom"}, {Name: "Jerry"}},
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `auto_id_model`(`name`) VALUES(?),(?);").
+					WillReturnResult(sqlmock.NewResult(11, 1))
+			},
+			wantVal: []*AutoIdModel{{Name: "Tom"}, {Name: "Jerry"}},
+		},
+		{
+			name: "safe mode batch",
+			opts: []DBOption{DBWithSafeInsertID()},
+			vals: []*AutoIdModel{{Name: "Tom"}, {Name: "Jerry"}},
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `auto_id_model`(`name`) VALUES(?),(?);").
+					WillReturnResult(sqlmock.NewResult(11, 2))
+			},
+			wantVal: []*AutoIdModel{{Name: "Tom"}, {Name: "Jerry"}},
+		},
+		{
+			name: "safe mode single",
+			opts: []DBOption{DBWithSafeInsertID()},
+			vals: []*AutoIdModel{{Name: "Tom"}},
+			mock: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("INSERT INTO `auto_id_model`(`name`) VALUES(?);").
+					WillReturnResult(sqlmock.NewResult(11, 1))
+			},
+			wantVal: []*AutoIdModel{{Id: 11, Name: "Tom"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockDB, mock, err := sqlmock.New(
+				sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			require.NoError(t, err)
+			defer func() { _ = mockDB.Close() }()
+			db, err := OpenDB("mysql", mockDB, tc.opts...)
+			require.NoError(t, err)
+			tc.mock(mock)
+			res := NewInserter[AutoIdModel](db).Values(tc.vals...).Exec(context.Background())
+			require.NoError(t, res.Err())
+			assert.Equal(t, tc.wantVal, tc.vals)
+			require.NoError(t, mock.ExpectationsWereMet())
+		})
+	}
+}
+
+func TestInserter_ExecBatch(t *testing.T) {
+	ctx := context.Background()
+	var sqls []string
+	db, err := Open("sqlite3", "file:batch.db?cache=shared&mode=memory",
+		DBWithMiddlewares(func(next HandleFunc) HandleFunc {
+			return func(ctx context.Context, qc *QueryContext) *QueryResult {
+				if qc.Type == "INSERT" {
+					q, err := qc.Query()
+					if err != nil {
+						return &QueryResult{Err: err}
+					}
+					sqls = append(sqls, q.SQL)
+				}
+				return next(ctx, qc)
+			}
+		}))
+	require.NoError(t, err)
+	require.NoError(t, NewCreater[AutoIdModel](db).Exec(ctx).Err())
+
+	vals := []*AutoIdModel{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "e"}}
+	res := NewInserter[AutoIdModel](db).Values(vals...).BatchSize(2).Exec(ctx)
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), affected)
+	id, err := res.LastInsertId()
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), id)
+	// 每一批都会经过 Middleware
+	assert.Equal(t, []string{
+		"INSERT INTO `auto_id_model`(`name`) VALUES(?),(?) RETURNING `id`;",
+		"INSERT INTO `auto_id_model`(`name`) VALUES(?),(?) RETURNING `id`;",
+		"INSERT INTO `auto_id_model`(`name`) VALUES(?) RETURNING `id`;",
+	}, sqls)
+	for idx, val := range vals {
+		assert.Equal(t, int64(idx+1), val.Id)
+	}
+
+	// 第二批主键冲突，整个事务回滚
+	res = NewInserter[AutoIdModel](db).Values(
+		&AutoIdModel{Id: 6, Name: "f"}, &AutoIdModel{Id: 7, Name: "g"},
+		&AutoIdModel{Id: 1, Name: "h"}).BatchSize(2).Exec(ctx)
+	assert.Error(t, res.Err())
+	got, err := NewSelector[AutoIdModel](db).Where(C("Id").GT(5)).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+}
+
+// ArchivedModel 用来测试 INSERT ... SELECT
+type ArchivedModel struct {
+	Id   int64 `orm:"primary_key,auto_increment"`
+	Name string
+}
+
+func TestInserter_ExecFromSelect(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("insert_select", t)
+	require.NoError(t, NewCreater[AutoIdModel](db).Exec(ctx).Err())
+	require.NoError(t, NewCreater[ArchivedModel](db).Exec(ctx).Err())
+	require.NoError(t, NewInserter[AutoIdModel](db).Values(
+		&AutoIdModel{Name: "a"}, &AutoIdModel{Name: "b"}, &AutoIdModel{Name: "c"}).Exec(ctx).Err())
+
+	archive := func(minId int) Result {
+		return NewInserter[ArchivedModel](db).
+			FromSelect(NewSelector[AutoIdModel](db).Where(C("Id").GTE(minId))).
+			OnConflictKey().DoNothing().Exec(ctx)
+	}
+	res := archive(2)
+	require.NoError(t, res.Err())
+	affected, err := res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+
+	// 重复执行的时候已经存在的行会被忽略
+	res = archive(1)
+	require.NoError(t, res.Err())
+	affected, err = res.RowsAffected()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), affected)
+
+	got, err := NewSelector[ArchivedModel](db).OrderBy(Asc("Id")).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*ArchivedModel{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}, {Id: 3, Name: "c"}}, got)
+}
+
+// CounterModel 用来测试冲突时累加
+type CounterModel struct {
+	Id      int64 `orm:"primary_key"`
+	Counter int
+	Version int
+}
+
+func TestInserter_ExecAccumulate(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("accumulate", t)
+	require.NoError(t, NewCreater[CounterModel](db).Exec(ctx).Err())
+
+	upsert := func(val *CounterModel) {
+		res := NewInserter[CounterModel](db).Values(val).
+			OnConflictKey().ConflictColumns("Id").
+			// 旧版本的数据不会覆盖新版本
+			Where(C("Version").LTE(Excluded("Version"))).
+			Update(Assign("Counter", C("Counter").Add(Excluded("Counter"))), C("Version")).Exec(ctx)
+		require.NoError(t, res.Err())
+	}
+	upsert(&CounterModel{Id: 1, Counter: 3, Version: 1})
+	upsert(&CounterModel{Id: 1, Counter: 4, Version: 2})
+	upsert(&CounterModel{Id: 1, Counter: 100, Version: 1})
+
+	got, err := NewSelector[CounterModel](db).Where(C("Id").EQ(1)).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &CounterModel{Id: 1, Counter: 7, Version: 2}, got)
+}
+
+func TestInserter_chunks(t *testing.T) {
+	vals := make([]*TestModel, 1100)
+	testCases := []struct {
+		name     string
+		i        *Inserter[TestModel]
+		wantSize []int
+	}{
+		{
+			// SQL Server 最多 2100 个参数，TestModel 有 4 列
+			name:     "mssql",
+			i:        NewInserter[TestModel](memoryDB(t, DBWithDialect(MSSQL))).Values(vals...),
+			wantSize: []int{525, 525, 50},
+		},
+		{
+			name:     "columns",
+			i:        NewInserter[TestModel](memoryDB(t, DBWithDialect(MSSQL))).Values(vals...).Columns("FirstName"),
+			wantSize: []int{1100},
+		},
+		{
+			name:     "batch size",
+			i:        NewInserter[TestModel](memoryDB(t)).Values(vals...).BatchSize(500),
+			wantSize: []int{500, 500, 100},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := tc.i.r.Get(&TestModel{})
+			require.NoError(t, err)
+			tc.i.model = m
+			var sizes []int
+			for _, chunk := range tc.i.chunks() {
+				sizes = append(sizes, len(chunk))
+			}
+			assert.Equal(t, tc.wantSize, sizes)
+		})
+	}
+}
+
 func TestInserter_Build(t *testing.T) {
-	db := memoryDB(t)
+	// 用例期望的是 MySQL 的 ON DUPLICATE KEY UPDATE
+	db := memoryDB(t, DBWithDialect(MySQL))
 	testCases := []struct {
 		name      string
 		q         QueryBuilder
@@ -173,6 +446,115 @@ func TestInserter_Build(t *testing.T) {
 					int64(2), "Da", int8(19), &sql.NullString{String: "Ming", Valid: true}},
 			},
 		},
+		{
+			name: "excluded expression",
+			q: NewInserter[TestModel](db).Columns("Id", "Age").
+				Values(&TestModel{Id: 1, Age: 18}).
+				OnConflictKey().Update(Assign("Age", C("Age").Add(Excluded("Age")))),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`age`) VALUES(?,?) " +
+					"ON DUPLICATE KEY UPDATE `age` = `age` + VALUES(`age`);",
+				Args: []any{int64(1), int8(18)},
+			},
+		},
+		{
+			name: "upsert where",
+			q: NewInserter[TestModel](db).Values(&TestModel{}).
+				OnConflictKey().Where(C("Age").GT(1)).Update(C("Age")),
+			wantErr: errs.ErrUnsupportedUpsertWhere,
+		},
+		{
+			// Excluded 只能在 upsert 里面使用
+			name: "excluded outside upsert",
+			q: NewUpdater[TestModel](db).Update(&TestModel{}).
+				Set(Assign("Age", Excluded("Age"))),
+			wantErr: errs.ErrExcludedOutsideUpsert,
+		},
+		{
+			name: "insert ignore",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				Values(&TestModel{Id: 1, FirstName: "Deng"}).
+				OnConflictKey().DoNothing(),
+			wantQuery: &Query{
+				SQL:  "INSERT IGNORE INTO `test_model`(`id`,`first_name`) VALUES(?,?);",
+				Args: []any{int64(1), "Deng"},
+			},
+		},
+		{
+			name: "insert select",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).Where(C("Age").GT(18))),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`) SELECT `id`,`first_name` FROM `test_model` WHERE `age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "insert ignore select",
+			q: NewInserter[TestModel](db).
+				FromSelect(NewSelector[TestModel](db).Where(C("Age").GT(18))).
+				OnConflictKey().DoNothing(),
+			wantQuery: &Query{
+				SQL:  "INSERT IGNORE INTO `test_model`(`id`,`first_name`,`age`,`last_name`) SELECT * FROM `test_model` WHERE `age` > ?;",
+				Args: []any{18},
+			},
+		},
+		{
+			name: "values and select",
+			q: NewInserter[TestModel](db).Values(&TestModel{}).
+				FromSelect(NewSelector[TestModel](db)),
+			wantErr: errs.ErrInsertValuesAndSelect,
+		},
+		{
+			name:    "invalid select",
+			q:       NewInserter[TestModel](db).FromSelect(NewSelector[TestModel](db).Where(C("Invalid").EQ(1))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestUpsert_MySQL8_Build(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(MySQL8))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "row alias",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName", "Age").
+				Values(&TestModel{Id: 1, FirstName: "Deng", Age: 18}).
+				OnConflictKey().Update(C("FirstName"), Assign("Age", C("Age").Add(Excluded("Age")))),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`,`age`) VALUES(?,?,?) AS `new` " +
+					"ON DUPLICATE KEY UPDATE `first_name` = `new`.`first_name`,`age` = `age` + `new`.`age`;",
+				Args: []any{int64(1), "Deng", int8(18)},
+			},
+		},
+		{
+			// 行别名不能用在 INSERT ... SELECT 里面
+			name: "insert select",
+			q: NewInserter[TestModel](db).Columns("Id", "Age").
+				FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("Age"))).
+				OnConflictKey().Update(C("Age")),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`age`) SELECT `id`,`age` FROM `test_model` " +
+					"ON DUPLICATE KEY UPDATE `age` = VALUES(`age`);",
+				Args: []any{},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -262,6 +644,48 @@ func TestUpsert_SQLite3_Build(t *testing.T) {
 					int64(2), "Da", int8(19), &sql.NullString{String: "Ming", Valid: true}},
 			},
 		},
+		{
+			name: "excluded expression",
+			q: NewInserter[TestModel](db).Columns("Id", "Age").
+				Values(&TestModel{Id: 1, Age: 18}).
+				OnConflictKey().ConflictColumns("Id").
+				Where(C("FirstName").NEQ("Tom"), Excluded("Age").GT(0)).
+				Update(Assign("Age", C("Age").Add(Excluded("Age")))),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`age`) VALUES(?,?) ON CONFLICT(`id`) " +
+					"DO UPDATE SET `age` = `age` + excluded.`age` WHERE (`first_name` != ?) AND (excluded.`age` > ?);",
+				Args: []any{int64(1), int8(18), "Tom", 0},
+			},
+		},
+		{
+			name: "excluded invalid column",
+			q: NewInserter[TestModel](db).Values(&TestModel{}).
+				OnConflictKey().ConflictColumns("Id").
+				Update(Assign("Age", Excluded("Invalid"))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "do nothing",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				Values(&TestModel{Id: 1, FirstName: "Deng"}).
+				OnConflictKey().ConflictColumns("Id").DoNothing(),
+			wantQuery: &Query{
+				SQL:  "INSERT INTO `test_model`(`id`,`first_name`) VALUES(?,?) ON CONFLICT(`id`) DO NOTHING;",
+				Args: []any{int64(1), "Deng"},
+			},
+		},
+		{
+			// INSERT ... SELECT 后面直接跟 ON CONFLICT 有语法歧义
+			name: "select do nothing",
+			q: NewInserter[TestModel](db).Columns("Id", "FirstName").
+				FromSelect(NewSelector[TestModel](db).Select(C("Id"), C("FirstName")).Where(C("Age").GT(18))).
+				OnConflictKey().DoNothing(),
+			wantQuery: &Query{
+				SQL: "INSERT INTO `test_model`(`id`,`first_name`) SELECT * FROM " +
+					"(SELECT `id`,`first_name` FROM `test_model` WHERE `age` > ?) WHERE true ON CONFLICT DO NOTHING;",
+				Args: []any{18},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
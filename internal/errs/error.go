@@ -18,6 +18,36 @@ var (
 	ErrInsertZeroRow          = errors.New("orm: 插入 0 行")
 	ErrNoUpdatedColumns       = errors.New("orm: 未指定更新的列")
 	ErrRegisterType           = errors.New("orm: 不支持的注册类型")
+	// ErrNoConflictColumns 例如 PostgreSQL 的 ON CONFLICT DO UPDATE 必须指定冲突列
+	ErrNoConflictColumns = errors.New("orm: 未指定冲突列")
+	// ErrUnsupportedReturning 当前方言不支持 RETURNING 子句，例如 MySQL
+	ErrUnsupportedReturning = errors.New("orm: 当前方言不支持 RETURNING")
+	// ErrInsertValuesAndSelect Inserter 不能同时指定 Values 和 FromSelect
+	ErrInsertValuesAndSelect = errors.New("orm: 不能同时使用 Values 和 FromSelect")
+	// ErrUnsupportedUpsertWhere 当前方言不支持给 upsert 的更新部分加条件，例如 MySQL
+	ErrUnsupportedUpsertWhere = errors.New("orm: 当前方言不支持 upsert 的更新条件")
+	// ErrExcludedOutsideUpsert Excluded 只能在 upsert 的更新部分使用
+	ErrExcludedOutsideUpsert = errors.New("orm: Excluded 只能在 upsert 中使用")
+	// ErrUnsupportedUpsert 当前方言没有实现 upsert
+	ErrUnsupportedUpsert = errors.New("orm: 当前方言不支持 upsert")
+	// ErrUnsupportedSchemaReader 当前方言不支持读取表结构，所以没办法自动迁移
+	ErrUnsupportedSchemaReader = errors.New("orm: 当前方言不支持读取表结构")
+	// ErrNoDownMigration 回滚的迁移没有对应的 .down.sql 文件
+	ErrNoDownMigration = errors.New("orm: 迁移没有 down 脚本")
+	// ErrUnsupportedLock 当前方言不支持 FOR UPDATE 这种行锁，例如 SQLite
+	ErrUnsupportedLock = errors.New("orm: 当前方言不支持行锁")
+	// ErrMixedAutoIncrementPK 一条插入语句里面有的行指定了自增主键，有的没有
+	ErrMixedAutoIncrementPK = errors.New("orm: 同一条插入语句里面部分行指定了自增主键")
+	// ErrUnsupportedUpdateJoin 当前方言不支持 UPDATE ... JOIN，例如 PostgreSQL 要使用 UPDATE ... FROM
+	ErrUnsupportedUpdateJoin = errors.New("orm: 当前方言不支持 UPDATE JOIN")
+	// ErrUnsupportedUpdateLimit 当前方言不支持 UPDATE 里面的 ORDER BY 和 LIMIT
+	ErrUnsupportedUpdateLimit = errors.New("orm: 当前方言不支持 UPDATE 的 ORDER BY 和 LIMIT")
+	// ErrUpdateJoinWithLimit UPDATE ... JOIN 不能使用 ORDER BY 和 LIMIT
+	ErrUpdateJoinWithLimit = errors.New("orm: UPDATE JOIN 不能使用 ORDER BY 和 LIMIT")
+	// ErrUnsupportedParenthesize 当前方言不允许集合操作的子查询加括号，例如 SQLite
+	ErrUnsupportedParenthesize = errors.New("orm: 当前方言不支持给集合操作的子查询加括号")
+	// ErrCaseWithoutWhen CASE 表达式至少要有一个 WHEN
+	ErrCaseWithoutWhen = errors.New("orm: CASE 表达式没有 WHEN")
 )
 
 func NewErrFailToRollbackTx(bizErr error, rbErr error, panicked bool) error {
@@ -58,6 +88,31 @@ func NewErrInvalidTagContent(tag string) error {
 	return fmt.Errorf("orm: 错误的标签设置: %s", tag)
 }
 
+// NewErrDuplicateColumn 模型里面有多个字段映射到了同一个列
+func NewErrDuplicateColumn(name string) error {
+	return fmt.Errorf("orm: 重复列 %s", name)
+}
+
+// NewErrEmbeddedPointer 嵌入的结构体指针没办法通过偏移量访问，不支持展开
+func NewErrEmbeddedPointer(name string) error {
+	return fmt.Errorf("orm: 不支持嵌入结构体指针 %s，请嵌入结构体", name)
+}
+
+// NewErrAmbiguousField 多个嵌入结构体在同一层级有同名字段
+func NewErrAmbiguousField(name string) error {
+	return fmt.Errorf("orm: 有歧义的字段 %s", name)
+}
+
+// NewErrUnknownRelation 模型上没有这个关联关系，注意它是字段名
+func NewErrUnknownRelation(name string) error {
+	return fmt.Errorf("orm: 未知关联关系 %s", name)
+}
+
+// NewErrNoTableForPart 组合结构体的某一部分在 FROM 里面找不到对应的表
+func NewErrNoTableForPart(name string) error {
+	return fmt.Errorf("orm: 找不到和 %s 对应的表", name)
+}
+
 // NewErrUnknownColumn 返回代表未知列的错误
 // 一般意味着你使用了错误的列名
 // 注意和 NewErrUnknownField 区别
@@ -69,6 +124,20 @@ func NewErrUnsupportedSelectable(exp any) error {
 	return fmt.Errorf("orm: 不支持的目标列 %v", exp)
 }
 
+// NewErrInvalidMigrationFile 迁移文件名必须是 NNN_name.up.sql 或者 NNN_name.down.sql
+func NewErrInvalidMigrationFile(name string) error {
+	return fmt.Errorf("orm: 错误的迁移文件名 %s", name)
+}
+
+func NewErrDuplicateMigration(version int64) error {
+	return fmt.Errorf("orm: 重复的迁移版本 %d", version)
+}
+
+// NewErrUnknownMigration 数据库里面记录的版本，或者用户指定的版本，没有对应的迁移文件
+func NewErrUnknownMigration(version int64) error {
+	return fmt.Errorf("orm: 未知的迁移版本 %d", version)
+}
+
 // NewUnsupportedDriverError 不支持驱动类型
 func NewUnsupportedDriverError(driver string) error {
 	return fmt.Errorf("orm: 不支持driver类型 %s", driver)
@@ -83,3 +152,13 @@ func NewUnsupportedDriverError(driver string) error {
 // @ErrUnsupportedExpressionType 40001
 // 发生该错误，主要是因为传入了不支持的 Expression 的实际类型
 // 一般来说，这是因为中间件
+
+// NewErrUnsupportedFunc 当前方言不支持该函数
+func NewErrUnsupportedFunc(name string) error {
+	return fmt.Errorf("orm: 当前方言不支持函数 %s", name)
+}
+
+// NewErrUnknownCTE 引用了没有通过 With 定义的 CTE
+func NewErrUnknownCTE(name string) error {
+	return fmt.Errorf("orm: 未知 CTE %s", name)
+}
@@ -187,3 +187,22 @@ func NewSimpleStruct(id uint64) *SimpleStruct {
 		},
 	}
 }
+
+// BaseModel 公共字段，用于测试匿名嵌入的结构体
+type BaseModel struct {
+	Id        uint64 `orm:"pk,auto_increment"`
+	CreatedAt int64
+	UpdatedAt int64
+}
+
+type Address struct {
+	City   string
+	Street string
+}
+
+// EmbeddedStruct 匿名嵌入 BaseModel，并且以 addr_ 为前缀展开 Address
+type EmbeddedStruct struct {
+	BaseModel
+	Name string
+	Addr Address `orm:"prefix=addr_"`
+}
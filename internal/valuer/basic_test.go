@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"orm/internal/errs"
 	"orm/internal/test"
 	"orm/model"
@@ -162,3 +163,47 @@ func Test_basicTypeValue_SetColumn(t *testing.T) {
 	}
 
 }
+
+func TestValue_Embedded(t *testing.T) {
+	r := model.NewRegistry()
+	meta, err := r.Get(&test.EmbeddedStruct{})
+	require.NoError(t, err)
+	want := &test.EmbeddedStruct{
+		BaseModel: test.BaseModel{Id: 1, CreatedAt: 100, UpdatedAt: 200},
+		Name:      "Tom",
+		Addr:      test.Address{City: "Shenzhen", Street: "Nanshan"},
+	}
+	creators := map[string]Creator{
+		"reflect": NewReflectValue,
+		"unsafe":  NewUnsafeValue,
+	}
+	for name, creator := range creators {
+		t.Run(name, func(t *testing.T) {
+			db, mock, err := sqlmock.New()
+			require.NoError(t, err)
+			defer func() { _ = db.Close() }()
+			mock.ExpectQuery("SELECT *").WillReturnRows(
+				sqlmock.NewRows([]string{"id", "created_at", "updated_at", "name", "addr_city", "addr_street"}).
+					AddRow(1, 100, 200, "Tom", "Shenzhen", "Nanshan"))
+			rows, err := db.Query("SELECT *")
+			require.NoError(t, err)
+			require.True(t, rows.Next())
+
+			val := &test.EmbeddedStruct{}
+			v := creator(val, meta)
+			require.NoError(t, v.SetColumns(rows))
+			assert.Equal(t, want, val)
+
+			for goName, wantFd := range map[string]any{
+				"Id":        uint64(1),
+				"UpdatedAt": int64(200),
+				"Name":      "Tom",
+				"Addr.City": "Shenzhen",
+			} {
+				fd, err := v.Field(goName)
+				require.NoError(t, err)
+				assert.Equal(t, wantFd, fd)
+			}
+		})
+	}
+}
@@ -75,7 +75,7 @@ func (u *reflectValue) SetColumns(rows *sql.Rows) error {
 
 	for i, col := range cols {
 		fd := u.meta.ColumnMap[col]
-		u.val.FieldByName(fd.GoName).Set(colElemVals[i])
+		u.val.FieldByIndex(fd.Index).Set(colElemVals[i])
 	}
 
 	return nil
@@ -86,6 +86,6 @@ func (u *reflectValue) Field(name string) (any, error) {
 	if !ok {
 		return nil, errs.NewErrUnknownField(name)
 	}
-	fdVal := u.val.Field(fd.Index)
+	fdVal := u.val.FieldByIndex(fd.Index)
 	return fdVal.Interface(), nil
 }
@@ -16,11 +16,18 @@ type QueryContext struct {
 	q       *Query
 }
 
+// Query 构造查询，结果会被缓存下来。
+// Builder 在 Build 之后会归还内部的 buffer，所以不能重复调用 Build
 func (qc *QueryContext) Query() (*Query, error) {
 	if qc.q != nil {
 		return qc.q, nil
 	}
-	return qc.Builder.Build()
+	q, err := qc.Builder.Build()
+	if err != nil {
+		return nil, err
+	}
+	qc.q = q
+	return q, nil
 }
 
 type QueryResult struct {
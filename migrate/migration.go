@@ -0,0 +1,82 @@
+package migrate
+
+import (
+	"io/fs"
+	"orm/internal/errs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration 代表一个版本的迁移，
+// 对应 NNN_name.up.sql 和 NNN_name.down.sql 两个文件
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	// Down 可以为空，这时候这个版本没办法回滚
+	Down string
+	// prefix 是文件名里面的版本号，保留了前导的 0
+	prefix string
+}
+
+var fileNamePattern = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Load 读取 fsys 根目录下的迁移文件，按照版本号从小到大排序。
+// 不是 .sql 结尾的文件会被忽略，
+// 如果迁移文件放在子目录里面，可以先用 fs.Sub
+func Load(fsys fs.FS) ([]*Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make(map[int64]*Migration, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".sql") {
+			continue
+		}
+		matches := fileNamePattern.FindStringSubmatch(name)
+		if matches == nil {
+			return nil, errs.NewErrInvalidMigrationFile(name)
+		}
+		version, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return nil, errs.NewErrInvalidMigrationFile(name)
+		}
+		m, ok := migrations[version]
+		if !ok {
+			m = &Migration{Version: version, Name: matches[2], prefix: matches[1]}
+			migrations[version] = m
+		} else if m.Name != matches[2] || m.prefix != matches[1] {
+			// 同一个版本号有两个不同名字的迁移
+			return nil, errs.NewErrDuplicateMigration(version)
+		}
+		content, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		if matches[3] == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	res := make([]*Migration, 0, len(migrations))
+	for _, m := range migrations {
+		if m.Up == "" {
+			return nil, errs.NewErrInvalidMigrationFile(m.fileName("up"))
+		}
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Version < res[j].Version
+	})
+	return res, nil
+}
+
+func (m *Migration) fileName(direction string) string {
+	return m.prefix + "_" + m.Name + "." + direction + ".sql"
+}
@@ -0,0 +1,198 @@
+package migrate
+
+import (
+	"context"
+	"io/fs"
+	"orm"
+	"orm/internal/errs"
+	"time"
+)
+
+// schemaMigration 记录已经执行过的迁移
+type schemaMigration struct {
+	Version int64 `orm:"primary_key"`
+	Name    string
+	// AppliedAt 毫秒时间戳
+	AppliedAt int64
+}
+
+func (schemaMigration) TableName() string {
+	return "orm_schema_migrations"
+}
+
+// Status 是某个版本的迁移状态
+type Status struct {
+	Version int64
+	Name    string
+	Applied bool
+	// AppliedAt 没有执行的时候是零值
+	AppliedAt time.Time
+}
+
+// Migrator 执行版本化的迁移。
+// 每一个迁移连同它的记录都在同一个事务里面执行，
+// 所以迁移脚本要么完整执行，要么什么都没做。
+// 注意 MySQL 的 DDL 会隐式提交事务，并且一个脚本有多条语句的时候，
+// 需要在 DSN 里面加上 multiStatements=true
+type Migrator struct {
+	db         *orm.DB
+	migrations []*Migration
+}
+
+// New 从 fsys 加载迁移文件，具体规则参考 Load
+func New(db *orm.DB, fsys fs.FS) (*Migrator, error) {
+	ms, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: ms,
+	}, nil
+}
+
+// Up 执行所有还没有执行的迁移
+func (m *Migrator) Up(ctx context.Context) error {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mg := range m.migrations {
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+		if err = m.up(ctx, mg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down 按照版本从大到小回滚最近的 n 个迁移
+func (m *Migrator) Down(ctx context.Context, n int) error {
+	records, err := m.records(ctx)
+	if err != nil {
+		return err
+	}
+	for i := len(records) - 1; i >= 0 && n > 0; i-- {
+		if err = m.downVersion(ctx, records[i].Version); err != nil {
+			return err
+		}
+		n--
+	}
+	return nil
+}
+
+// Goto 迁移到指定版本：执行不超过 version 的所有迁移，
+// 并且回滚所有大于 version 的迁移。version 为 0 会回滚全部迁移
+func (m *Migrator) Goto(ctx context.Context, version int64) error {
+	if version != 0 && m.find(version) == nil {
+		return errs.NewErrUnknownMigration(version)
+	}
+	records, err := m.records(ctx)
+	if err != nil {
+		return err
+	}
+	applied := make(map[int64]struct{}, len(records))
+	for i := len(records) - 1; i >= 0; i-- {
+		v := records[i].Version
+		applied[v] = struct{}{}
+		if v <= version {
+			continue
+		}
+		if err = m.downVersion(ctx, v); err != nil {
+			return err
+		}
+	}
+	for _, mg := range m.migrations {
+		if mg.Version > version {
+			break
+		}
+		if _, ok := applied[mg.Version]; ok {
+			continue
+		}
+		if err = m.up(ctx, mg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Status 返回所有迁移文件的状态，按照版本从小到大排序
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]Status, 0, len(m.migrations))
+	for _, mg := range m.migrations {
+		st := Status{Version: mg.Version, Name: mg.Name}
+		if r, ok := applied[mg.Version]; ok {
+			st.Applied = true
+			st.AppliedAt = time.UnixMilli(r.AppliedAt)
+		}
+		res = append(res, st)
+	}
+	return res, nil
+}
+
+func (m *Migrator) up(ctx context.Context, mg *Migration) error {
+	return m.db.DoTx(ctx, func(ctx context.Context, tx *orm.Tx) error {
+		if err := orm.RawQuery[any](tx, mg.Up).Exec(ctx).Err(); err != nil {
+			return err
+		}
+		return orm.NewInserter[schemaMigration](tx).Values(&schemaMigration{
+			Version:   mg.Version,
+			Name:      mg.Name,
+			AppliedAt: time.Now().UnixMilli(),
+		}).Exec(ctx).Err()
+	}, nil)
+}
+
+func (m *Migrator) downVersion(ctx context.Context, version int64) error {
+	mg := m.find(version)
+	if mg == nil {
+		return errs.NewErrUnknownMigration(version)
+	}
+	if mg.Down == "" {
+		return errs.ErrNoDownMigration
+	}
+	return m.db.DoTx(ctx, func(ctx context.Context, tx *orm.Tx) error {
+		if err := orm.RawQuery[any](tx, mg.Down).Exec(ctx).Err(); err != nil {
+			return err
+		}
+		return orm.NewDeleter[schemaMigration](tx).
+			Where(orm.C("Version").EQ(version)).Exec(ctx).Err()
+	}, nil)
+}
+
+func (m *Migrator) find(version int64) *Migration {
+	for _, mg := range m.migrations {
+		if mg.Version == version {
+			return mg
+		}
+	}
+	return nil
+}
+
+// records 返回已经执行过的迁移，按照版本从小到大排序
+func (m *Migrator) records(ctx context.Context) ([]*schemaMigration, error) {
+	err := orm.NewCreater[schemaMigration](m.db).IfNotExists().Exec(ctx).Err()
+	if err != nil {
+		return nil, err
+	}
+	return orm.NewSelector[schemaMigration](m.db).
+		OrderBy(orm.Asc("Version")).GetMulti(ctx)
+}
+
+func (m *Migrator) applied(ctx context.Context) (map[int64]*schemaMigration, error) {
+	records, err := m.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[int64]*schemaMigration, len(records))
+	for _, r := range records {
+		res[r.Version] = r
+	}
+	return res, nil
+}
@@ -0,0 +1,149 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"orm"
+	"orm/internal/errs"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoad(t *testing.T) {
+	testCases := []struct {
+		name    string
+		fsys    fstest.MapFS
+		want    []*Migration
+		wantErr error
+	}{
+		{
+			name: "sorted",
+			fsys: fstest.MapFS{
+				"002_add_age.up.sql":       {Data: []byte("ALTER TABLE user ADD COLUMN age int;")},
+				"001_create_user.up.sql":   {Data: []byte("CREATE TABLE user(id int);")},
+				"001_create_user.down.sql": {Data: []byte("DROP TABLE user;")},
+				"README.md":                {Data: []byte("ignored")},
+				"sub/003_ignored.up.sql":   {Data: []byte("ignored")},
+			},
+			want: []*Migration{
+				{Version: 1, Name: "create_user", prefix: "001",
+					Up: "CREATE TABLE user(id int);", Down: "DROP TABLE user;"},
+				{Version: 2, Name: "add_age", prefix: "002",
+					Up: "ALTER TABLE user ADD COLUMN age int;"},
+			},
+		},
+		{
+			name: "invalid file name",
+			fsys: fstest.MapFS{
+				"create_user.up.sql": {Data: []byte("CREATE TABLE user(id int);")},
+			},
+			wantErr: errs.NewErrInvalidMigrationFile("create_user.up.sql"),
+		},
+		{
+			name: "duplicate version",
+			fsys: fstest.MapFS{
+				"001_create_user.up.sql":  {Data: []byte("CREATE TABLE user(id int);")},
+				"001_create_order.up.sql": {Data: []byte("CREATE TABLE order(id int);")},
+			},
+			wantErr: errs.NewErrDuplicateMigration(1),
+		},
+		{
+			name: "down only",
+			fsys: fstest.MapFS{
+				"001_create_user.down.sql": {Data: []byte("DROP TABLE user;")},
+			},
+			wantErr: errs.NewErrInvalidMigrationFile("001_create_user.up.sql"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ms, err := Load(tc.fsys)
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, ms)
+		})
+	}
+}
+
+var testFS = fstest.MapFS{
+	"001_create_user.up.sql":   {Data: []byte("CREATE TABLE `user`(`id` integer PRIMARY KEY);")},
+	"001_create_user.down.sql": {Data: []byte("DROP TABLE `user`;")},
+	"002_add_age.up.sql":       {Data: []byte("ALTER TABLE `user` ADD COLUMN `age` integer;")},
+	"002_add_age.down.sql":     {Data: []byte("ALTER TABLE `user` DROP COLUMN `age`;")},
+	"003_create_order.up.sql": {Data: []byte("CREATE TABLE `order`(`id` integer PRIMARY KEY);\n" +
+		"CREATE INDEX `idx_order_id` ON `order`(`id`);")},
+	"003_create_order.down.sql": {Data: []byte("DROP TABLE `order`;")},
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db, err := orm.Open("sqlite3", "file:migrate.db?cache=shared&mode=memory")
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := New(db, testFS)
+	require.NoError(t, err)
+
+	applied := func() []int64 {
+		sts, err := m.Status(ctx)
+		require.NoError(t, err)
+		res := make([]int64, 0, len(sts))
+		for _, st := range sts {
+			if st.Applied {
+				assert.False(t, st.AppliedAt.IsZero())
+				res = append(res, st.Version)
+			}
+		}
+		return res
+	}
+
+	assert.Equal(t, []int64{}, applied())
+	require.NoError(t, m.Up(ctx))
+	assert.Equal(t, []int64{1, 2, 3}, applied())
+	// 重复执行不会有任何效果
+	require.NoError(t, m.Up(ctx))
+	assert.Equal(t, []int64{1, 2, 3}, applied())
+
+	require.NoError(t, m.Down(ctx, 2))
+	assert.Equal(t, []int64{1}, applied())
+	// age 列已经被删除
+	assert.Error(t, orm.RawQuery[any](db, "SELECT `age` FROM `user`;").Exec(ctx).Err())
+
+	require.NoError(t, m.Goto(ctx, 3))
+	assert.Equal(t, []int64{1, 2, 3}, applied())
+	require.NoError(t, m.Goto(ctx, 2))
+	assert.Equal(t, []int64{1, 2}, applied())
+	require.NoError(t, m.Goto(ctx, 0))
+	assert.Equal(t, []int64{}, applied())
+	assert.Equal(t, errs.NewErrUnknownMigration(4), m.Goto(ctx, 4))
+}
+
+func TestMigrator_Rollback(t *testing.T) {
+	ctx := context.Background()
+	db, err := orm.Open("sqlite3", "file:migrate_rollback.db?cache=shared&mode=memory")
+	require.NoError(t, err)
+	defer func() {
+		_ = db.Close()
+	}()
+	m, err := New(db, fstest.MapFS{
+		"001_create_user.up.sql": {Data: []byte("CREATE TABLE `user`(`id` integer PRIMARY KEY);")},
+		// 第二条语句会失败，整个迁移都会回滚
+		"002_broken.up.sql": {Data: []byte("CREATE TABLE `order`(`id` integer);\nINSERT INTO `unknown` VALUES (1);")},
+	})
+	require.NoError(t, err)
+	assert.Error(t, m.Up(ctx))
+	sts, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.True(t, sts[0].Applied)
+	assert.False(t, sts[1].Applied)
+	assert.Error(t, orm.RawQuery[any](db, "SELECT * FROM `order`;").Exec(ctx).Err())
+
+	// 没有 down 脚本
+	assert.True(t, errors.Is(m.Down(ctx, 1), errs.ErrNoDownMigration))
+}
@@ -0,0 +1,357 @@
+package orm
+
+import (
+	"context"
+	"github.com/valyala/bytebufferpool"
+	"orm/internal/errs"
+	"orm/model"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// Migrator 对比模型和数据库当前的表结构，
+// 生成并执行补齐差异所需的 DDL。
+// 它只会新增表、列和索引，不会删除或者修改已有的列，
+// 所以可以放心地在每次发布的时候执行
+type Migrator struct {
+	sess   session
+	core   core
+	dryRun bool
+}
+
+type MigratorOption func(m *Migrator)
+
+// MigratorWithDryRun 只生成 DDL，不执行
+func MigratorWithDryRun() MigratorOption {
+	return func(m *Migrator) {
+		m.dryRun = true
+	}
+}
+
+func NewMigrator(sess session, opts ...MigratorOption) *Migrator {
+	m := &Migrator{
+		sess: sess,
+		core: sess.getCore(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Plan 返回让数据库和模型保持一致所需要执行的语句，
+// vals 是模型，例如 &User{}
+func (m *Migrator) Plan(ctx context.Context, vals ...any) ([]*Query, error) {
+	reader, ok := m.core.dialect.(schemaReader)
+	if !ok {
+		return nil, errs.ErrUnsupportedSchemaReader
+	}
+	res := make([]*Query, 0, len(vals))
+	for _, val := range vals {
+		meta, err := m.core.r.Get(val)
+		if err != nil {
+			return nil, err
+		}
+		ts, err := reader.readTable(ctx, m.sess, meta.TableName)
+		if err != nil {
+			return nil, err
+		}
+		bs, err := m.diff(meta, ts)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range bs {
+			q, err := b.Build()
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, q)
+		}
+	}
+	return res, nil
+}
+
+// AutoMigrate 执行 Plan 得到的语句，并且将其返回。
+// dry-run 模式下只返回不执行。
+// 支持事务性 DDL 的数据库（例如 SQLite）会在同一个事务里面执行所有的语句，
+// 失败的时候全部回滚；MySQL 的 DDL 会隐式提交事务，所以中途失败的时候已经执行的语句不会回滚
+func (m *Migrator) AutoMigrate(ctx context.Context, vals ...any) ([]*Query, error) {
+	qs, err := m.Plan(ctx, vals...)
+	if err != nil || m.dryRun {
+		return qs, err
+	}
+	db, ok := m.sess.(*DB)
+	if !ok || !m.core.dialect.(schemaReader).transactionalDDL() {
+		return qs, execQueries(ctx, m.sess, qs)
+	}
+	return qs, db.DoTx(ctx, func(ctx context.Context, tx *Tx) error {
+		return execQueries(ctx, tx, qs)
+	}, nil)
+}
+
+func execQueries(ctx context.Context, sess session, qs []*Query) error {
+	for _, q := range qs {
+		if err := RawQuery[any](sess, q.SQL, q.Args...).Exec(ctx).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diff 计算模型和表结构的差异，ts 为 nil 说明表不存在
+func (m *Migrator) diff(meta *model.Model, ts *tableSchema) ([]QueryBuilder, error) {
+	if ts == nil {
+		tc := newTableCreater(m.core, meta, false)
+		res := []QueryBuilder{tc}
+		for _, ic := range tc.indexCreaters() {
+			res = append(res, ic)
+		}
+		return res, nil
+	}
+	res := make([]QueryBuilder, 0, 4)
+	for _, fd := range meta.Fields {
+		if _, ok := ts.columns[fd.ColName]; ok {
+			continue
+		}
+		res = append(res, &columnAdder{
+			Builder: Builder{
+				core:   m.core,
+				buffer: bytebufferpool.Get(),
+				model:  meta,
+			},
+			field:           fd,
+			implicitDefault: m.core.dialect.(schemaReader).implicitDefault(),
+		})
+	}
+	for _, idx := range meta.Indexes {
+		if ts.hasIndex(idx) {
+			continue
+		}
+		res = append(res, newIndexCreater(m.core, meta, idx, false))
+	}
+	return res, nil
+}
+
+// columnAdder 构造 ALTER TABLE ADD COLUMN 语句
+type columnAdder struct {
+	Builder
+	field *model.Field
+	// implicitDefault 数据库会不会用零值填充已有的行，见 schemaReader
+	implicitDefault bool
+}
+
+// Build 已有的行没有新列的值，所以没有指定默认值的 NOT NULL 列，
+// 会使用类型的零值作为默认值，不知道零值的类型则添加为允许 NULL 的列
+func (c *columnAdder) Build() (*Query, error) {
+	defer c.release()
+	c.writeString("ALTER TABLE ")
+	c.quote(c.model.TableName)
+	c.writeString(" ADD COLUMN ")
+	fd := c.field
+	if !fd.Nullable && fd.Default == "" && !fd.AutoIncrement && !c.implicitDefault {
+		cp := *fd
+		cp.Default = zeroDefault(columnType(fd.Type))
+		cp.Nullable = cp.Default == ""
+		fd = &cp
+	}
+	c.buildColumnDefinition(fd)
+	c.end()
+	return &Query{SQL: c.buffer.String()}, nil
+}
+
+// zeroDefault 返回 typ 零值的字面量，不知道的时候返回空字符串
+func zeroDefault(typ reflect.Type) string {
+	switch typ.Kind() {
+	case reflect.Bool:
+		return "FALSE"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "0"
+	case reflect.String:
+		return "''"
+	case reflect.Slice:
+		if typ.Elem().Kind() == reflect.Uint8 {
+			return "''"
+		}
+	case reflect.Struct:
+		if typ == reflect.TypeOf(time.Time{}) {
+			return "'0001-01-01 00:00:00'"
+		}
+	}
+	return ""
+}
+
+// schemaReader 读取表的当前结构，并且描述执行 DDL 的特性，
+// 只有实现了该接口的 Dialect 才能使用 Migrator
+type schemaReader interface {
+	// readTable 表不存在的时候返回 nil
+	readTable(ctx context.Context, sess session, table string) (*tableSchema, error)
+	// implicitDefault 添加没有默认值的 NOT NULL 列的时候，
+	// 数据库会不会用类型的零值填充已有的行。MySQL 会，SQLite 和 PostgreSQL 会报错
+	implicitDefault() bool
+	// transactionalDDL DDL 能不能在事务里面执行并且回滚，MySQL 的 DDL 会隐式提交事务
+	transactionalDDL() bool
+}
+
+type tableSchema struct {
+	columns map[string]struct{}
+	indexes []indexSchema
+}
+
+type indexSchema struct {
+	unique  bool
+	columns []string
+}
+
+// hasIndex 按照列和唯一性判断索引是否已经存在，而不是按照索引名。
+// 因为像 SQLite 会给 UNIQUE 约束生成自己的索引名
+func (t *tableSchema) hasIndex(idx *model.Index) bool {
+	for _, is := range t.indexes {
+		if is.unique != idx.Unique || len(is.columns) != len(idx.Fields) {
+			continue
+		}
+		match := true
+		for i, fd := range idx.Fields {
+			if !strings.EqualFold(is.columns[i], fd.ColName) {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *mysqlDialect) implicitDefault() bool {
+	return true
+}
+
+func (d *mysqlDialect) transactionalDDL() bool {
+	return false
+}
+
+func (d *mysqlDialect) readTable(ctx context.Context, sess session, table string) (*tableSchema, error) {
+	rows, err := sess.queryContext(ctx, "SELECT `COLUMN_NAME` FROM `information_schema`.`COLUMNS` "+
+		"WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_NAME` = ?;", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ts := &tableSchema{columns: make(map[string]struct{}, 16)}
+	for rows.Next() {
+		var col string
+		if err = rows.Scan(&col); err != nil {
+			return nil, err
+		}
+		ts.columns[col] = struct{}{}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ts.columns) == 0 {
+		return nil, nil
+	}
+
+	idxRows, err := sess.queryContext(ctx, "SELECT `INDEX_NAME`,`NON_UNIQUE`,`COLUMN_NAME` "+
+		"FROM `information_schema`.`STATISTICS` WHERE `TABLE_SCHEMA` = DATABASE() AND `TABLE_NAME` = ? "+
+		"ORDER BY `INDEX_NAME`,`SEQ_IN_INDEX`;", table)
+	if err != nil {
+		return nil, err
+	}
+	defer idxRows.Close()
+	var last string
+	for idxRows.Next() {
+		var name, col string
+		var nonUnique bool
+		if err = idxRows.Scan(&name, &nonUnique, &col); err != nil {
+			return nil, err
+		}
+		if name == "PRIMARY" {
+			continue
+		}
+		if name != last || len(ts.indexes) == 0 {
+			ts.indexes = append(ts.indexes, indexSchema{unique: !nonUnique})
+			last = name
+		}
+		is := &ts.indexes[len(ts.indexes)-1]
+		is.columns = append(is.columns, col)
+	}
+	return ts, idxRows.Err()
+}
+
+func (d *sqlite3Dialect) implicitDefault() bool {
+	return false
+}
+
+func (d *sqlite3Dialect) transactionalDDL() bool {
+	return true
+}
+
+func (d *sqlite3Dialect) readTable(ctx context.Context, sess session, table string) (*tableSchema, error) {
+	rows, err := sess.queryContext(ctx, "SELECT `name` FROM pragma_table_info(?);", table)
+	if err != nil {
+		return nil, err
+	}
+	ts := &tableSchema{columns: make(map[string]struct{}, 16)}
+	for rows.Next() {
+		var col string
+		if err = rows.Scan(&col); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		ts.columns[col] = struct{}{}
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ts.columns) == 0 {
+		return nil, nil
+	}
+
+	// 先把索引名都读出来，再逐个读取索引的列
+	rows, err = sess.queryContext(ctx,
+		"SELECT `name`,`unique` FROM pragma_index_list(?) WHERE `origin` <> 'pk';", table)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, 4)
+	for rows.Next() {
+		var name string
+		var is indexSchema
+		if err = rows.Scan(&name, &is.unique); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		names = append(names, name)
+		ts.indexes = append(ts.indexes, is)
+	}
+	_ = rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		rows, err = sess.queryContext(ctx,
+			"SELECT `name` FROM pragma_index_info(?) ORDER BY `seqno`;", name)
+		if err != nil {
+			return nil, err
+		}
+		for rows.Next() {
+			var col string
+			if err = rows.Scan(&col); err != nil {
+				_ = rows.Close()
+				return nil, err
+			}
+			ts.indexes[i].columns = append(ts.indexes[i].columns, col)
+		}
+		_ = rows.Close()
+		if err = rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return ts, nil
+}
@@ -0,0 +1,168 @@
+package orm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"orm/internal/errs"
+	"testing"
+	"time"
+)
+
+type MigrateTestModel struct {
+	Id    int64  `orm:"primary_key,auto_increment"`
+	Name  string `orm:"unique"`
+	Age   *int   `orm:"index"`
+	Email *string
+}
+
+func TestMigrator_AutoMigrate(t *testing.T) {
+	ctx := context.Background()
+	testCases := []struct {
+		name   string
+		dbName string
+		// before 准备已有的表结构
+		before  []string
+		opts    []MigratorOption
+		wantQs  []*Query
+		wantErr error
+		// wantQsAfter 执行之后再次 Plan 的结果
+		wantQsAfter []*Query
+	}{
+		{
+			name:   "create table",
+			dbName: "migrate_create",
+			wantQs: []*Query{
+				{SQL: "CREATE TABLE `migrate_test_model` (`id` integer NOT NULL,`name` text NOT NULL," +
+					"`age` integer,`email` text,PRIMARY KEY(`id`)," +
+					"CONSTRAINT `uk_migrate_test_model_name` UNIQUE(`name`));"},
+				{SQL: "CREATE INDEX `idx_migrate_test_model_age` ON `migrate_test_model`(`age`);"},
+			},
+			wantQsAfter: []*Query{},
+		},
+		{
+			name:   "add columns and indexes",
+			dbName: "migrate_alter",
+			before: []string{
+				"CREATE TABLE `migrate_test_model` (`id` integer PRIMARY KEY,`name` text NOT NULL UNIQUE);",
+			},
+			wantQs: []*Query{
+				{SQL: "ALTER TABLE `migrate_test_model` ADD COLUMN `age` integer;"},
+				{SQL: "ALTER TABLE `migrate_test_model` ADD COLUMN `email` text;"},
+				{SQL: "CREATE INDEX `idx_migrate_test_model_age` ON `migrate_test_model`(`age`);"},
+			},
+			wantQsAfter: []*Query{},
+		},
+		{
+			name:   "up to date",
+			dbName: "migrate_noop",
+			before: []string{
+				"CREATE TABLE `migrate_test_model` (`id` integer PRIMARY KEY,`name` text NOT NULL," +
+					"`age` integer,`email` text,CONSTRAINT `uk` UNIQUE(`name`));",
+				"CREATE INDEX `idx_age` ON `migrate_test_model`(`age`);",
+			},
+			wantQs:      []*Query{},
+			wantQsAfter: []*Query{},
+		},
+		{
+			name:   "dry run",
+			dbName: "migrate_dry_run",
+			before: []string{
+				"CREATE TABLE `migrate_test_model` (`id` integer PRIMARY KEY,`name` text NOT NULL UNIQUE,`age` integer);",
+			},
+			opts: []MigratorOption{MigratorWithDryRun()},
+			wantQs: []*Query{
+				{SQL: "ALTER TABLE `migrate_test_model` ADD COLUMN `email` text;"},
+				{SQL: "CREATE INDEX `idx_migrate_test_model_age` ON `migrate_test_model`(`age`);"},
+			},
+			// 什么都没有执行
+			wantQsAfter: []*Query{
+				{SQL: "ALTER TABLE `migrate_test_model` ADD COLUMN `email` text;"},
+				{SQL: "CREATE INDEX `idx_migrate_test_model_age` ON `migrate_test_model`(`age`);"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := memoryDBWithDB(tc.dbName, t)
+			defer func() {
+				_ = db.Close()
+			}()
+			for _, s := range tc.before {
+				require.NoError(t, RawQuery[any](db, s).Exec(ctx).Err())
+			}
+			m := NewMigrator(db, tc.opts...)
+			qs, err := m.AutoMigrate(ctx, &MigrateTestModel{})
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQs, qs)
+			qs, err = m.Plan(ctx, &MigrateTestModel{})
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQsAfter, qs)
+		})
+	}
+}
+
+// MigrateNotNullModel 用来测试给已经有数据的表添加 NOT NULL 列
+type MigrateNotNullModel struct {
+	Id        int64 `orm:"primary_key,auto_increment"`
+	Name      string
+	Score     float64
+	Active    bool
+	Nickname  string `orm:"default='Tom'"`
+	CreatedAt time.Time
+}
+
+func TestMigrator_AutoMigrateNotNull(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("migrate_not_null", t)
+	defer func() {
+		_ = db.Close()
+	}()
+	require.NoError(t, RawQuery[any](db, "CREATE TABLE `migrate_not_null_model` (`id` integer PRIMARY KEY);").Exec(ctx).Err())
+	require.NoError(t, RawQuery[any](db, "INSERT INTO `migrate_not_null_model`(`id`) VALUES(1);").Exec(ctx).Err())
+
+	qs, err := NewMigrator(db).AutoMigrate(ctx, &MigrateNotNullModel{})
+	require.NoError(t, err)
+	assert.Equal(t, []*Query{
+		{SQL: "ALTER TABLE `migrate_not_null_model` ADD COLUMN `name` text NOT NULL DEFAULT '';"},
+		{SQL: "ALTER TABLE `migrate_not_null_model` ADD COLUMN `score` real NOT NULL DEFAULT 0;"},
+		{SQL: "ALTER TABLE `migrate_not_null_model` ADD COLUMN `active` bool NOT NULL DEFAULT FALSE;"},
+		{SQL: "ALTER TABLE `migrate_not_null_model` ADD COLUMN `nickname` text NOT NULL DEFAULT 'Tom';"},
+		{SQL: "ALTER TABLE `migrate_not_null_model` ADD COLUMN `created_at` datetime NOT NULL DEFAULT '0001-01-01 00:00:00';"},
+	}, qs)
+
+	res, err := NewSelector[MigrateNotNullModel](db).Select(C("Id"), C("Name"), C("Nickname")).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &MigrateNotNullModel{Id: 1, Nickname: "Tom"}, res)
+}
+
+func TestMigrator_AutoMigrateRollback(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("migrate_rollback", t)
+	defer func() {
+		_ = db.Close()
+	}()
+	require.NoError(t, RawQuery[any](db, "CREATE TABLE `migrate_test_model` (`id` integer PRIMARY KEY,`age` integer,`email` text);").Exec(ctx).Err())
+	require.NoError(t, RawQuery[any](db, "INSERT INTO `migrate_test_model`(`id`) VALUES(1),(2);").Exec(ctx).Err())
+
+	// 新增的 name 列默认值都是 ''，所以唯一索引会创建失败，之前添加的列也要回滚
+	m := NewMigrator(db)
+	_, err := m.AutoMigrate(ctx, &MigrateTestModel{})
+	assert.Error(t, err)
+	qs, err := m.Plan(ctx, &MigrateTestModel{})
+	require.NoError(t, err)
+	assert.Equal(t, []*Query{
+		{SQL: "ALTER TABLE `migrate_test_model` ADD COLUMN `name` text NOT NULL DEFAULT '';"},
+		{SQL: "CREATE UNIQUE INDEX `uk_migrate_test_model_name` ON `migrate_test_model`(`name`);"},
+		{SQL: "CREATE INDEX `idx_migrate_test_model_age` ON `migrate_test_model`(`age`);"},
+	}, qs)
+}
+
+func TestMigrator_Unsupported(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(Postgres))
+	_, err := NewMigrator(db).Plan(context.Background(), &MigrateTestModel{})
+	assert.Equal(t, errs.ErrUnsupportedSchemaReader, err)
+}
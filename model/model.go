@@ -11,9 +11,89 @@ type Field struct {
 	GoName  string
 	Type    reflect.Type
 
-	// Offset 相对于对象起始地址的字段偏移量
+	// Offset 相对于对象起始地址的字段偏移量，嵌套结构体里面的字段是累加之后的偏移量
 	Offset uintptr
-	Index  int
+	// Index 字段的索引路径，和 reflect.Value.FieldByIndex 的参数一致
+	Index []int
+
+	// 以下是生成 DDL 需要用到的元数据，都是通过标签声明的
+
+	// PrimaryKey 是否是主键
+	PrimaryKey bool
+	// AutoIncrement 是否自增
+	AutoIncrement bool
+	// Nullable 是否允许 NULL，
+	// 指针，切片和 sql.NullXXX 这一类类型默认允许，可以通过 nullable 和 not_null 标签修改
+	Nullable bool
+	// Default 列的默认值，会原样写入 DDL，所以字符串需要自己加上引号
+	Default string
+	// SQLType 用户指定的列类型，为空的时候使用 Dialect.ColTypeOf 的结果
+	SQLType string
+	// Size 列的长度，目前只用于在没有指定 SQLType 的时候把字符串映射为 varchar(Size)
+	Size int
+}
+
+// Index 索引
+type Index struct {
+	// Name 索引名，为空的时候由使用方生成
+	Name   string
+	Unique bool
+	// Fields 索引包含的字段，按照声明的顺序排列
+	Fields []*Field
+}
+
+// RelationKind 关联关系的类型
+type RelationKind uint8
+
+const (
+	// HasOne 对方的 ForeignKey 指向自己，对方最多只有一个，例如 User 和 Profile
+	HasOne RelationKind = iota + 1
+	// HasMany 对方的 ForeignKey 指向自己，例如 User 和 Order
+	HasMany
+	// BelongsTo 自己的 ForeignKey 指向对方，例如 Order 和 User
+	BelongsTo
+	// ManyToMany 通过中间表关联，例如 User 和 Tag
+	ManyToMany
+)
+
+// Relation 关联关系，通过 has_one, has_many, belongs_to 和 many_to_many 标签声明。
+// 关联字段不会被当作列
+type Relation struct {
+	Kind RelationKind
+	// GoName 关联字段的名字，例如 Orders
+	GoName string
+	// Type 关联字段的类型，例如 []*Order
+	Type reflect.Type
+	// Elem 关联的模型的类型，例如 Order
+	Elem  reflect.Type
+	Index []int
+	// ForeignKey 外键的字段名。
+	// HasOne 和 HasMany 里面是对方的字段，默认是 自己的类型名 + Id，例如 UserId；
+	// BelongsTo 里面是自己的字段，默认是 关联字段名 + Id，例如 UserId。
+	// ManyToMany 不使用
+	ForeignKey string
+	// References 外键引用的字段名，为空的时候使用被引用的模型的第一个主键，
+	// 没有主键就是 Id。
+	// ManyToMany 里面是自己的字段
+	References string
+	// JoinTable 中间表的表名
+	JoinTable string
+	// JoinForeignKey 中间表里面指向自己的列名，默认是 自己的类型名_id，例如 user_id
+	JoinForeignKey string
+	// JoinReferences 中间表里面指向对方的列名，默认是 对方的类型名_id，例如 tag_id
+	JoinReferences string
+}
+
+// Part 组合结构体里面的一个部分，用于接收 JOIN 查询里面某一张表的列，
+// 通过 alias 标签声明
+type Part struct {
+	GoName string
+	// Alias 对应的表的别名，为空的时候按照类型匹配 FROM 里面的表
+	Alias  string
+	Index  []int
+	Offset uintptr
+	// Model 这一部分自身的元数据
+	Model *Model
 }
 
 type Model struct {
@@ -24,6 +104,14 @@ type Model struct {
 	ColumnMap map[string]*Field
 	Type      reflect.Type
 	Fields    []*Field
+	// PrimaryKeys 主键字段，按照声明的顺序排列，组合主键会有多个
+	PrimaryKeys []*Field
+	// Indexes 通过 index 和 unique 标签声明的索引
+	Indexes []*Index
+	// Relations 字段名到关联关系
+	Relations map[string]*Relation
+	// Parts 组合结构体的各个部分，不为空的时候说明这是一个用来接收 JOIN 结果的结构体
+	Parts []*Part
 }
 
 type Option func(model *Model) error
@@ -53,8 +141,57 @@ func WithColumnName(field string, columnName string) Option {
 // 方便用户查找，和我们后期维护
 const (
 	tagKeyColumn = "column"
+	// tagKeyPrimaryKey 主键，组合主键就在多个字段上都加上该标签，
+	// 也可以简写为 pk
+	tagKeyPrimaryKey    = "primary_key"
+	tagKeyPK            = "pk"
+	tagKeyAutoIncrement = "auto_increment"
+	tagKeyNullable      = "nullable"
+	tagKeyNotNull       = "not_null"
+	tagKeyDefault       = "default"
+	tagKeyType          = "type"
+	tagKeySize          = "size"
+	// tagKeyIgnore 写作 orm:"-"，忽略该字段
+	tagKeyIgnore = "-"
+	// tagKeyPrefix 展开具名的结构体字段，并且给里面的列名加上前缀，
+	// 例如 orm:"prefix=addr_"。匿名嵌入的结构体总是会被展开
+	tagKeyPrefix = "prefix"
+
+	// tagKeyAlias 声明组合结构体的一部分，例如 orm:"alias=u"，
+	// 也可以只写 alias，这时候按照类型匹配
+	tagKeyAlias = "alias"
+
+	// 关联关系
+	tagKeyHasOne         = "has_one"
+	tagKeyHasMany        = "has_many"
+	tagKeyBelongsTo      = "belongs_to"
+	tagKeyManyToMany     = "many_to_many"
+	tagKeyForeignKey     = "foreign_key"
+	tagKeyReferences     = "references"
+	tagKeyJoinForeignKey = "join_foreign_key"
+	tagKeyJoinReferences = "join_references"
+	// tagKeyIndex 普通索引，可以写作 index 或者 index=idx_name，
+	// 多个字段使用同一个索引名就构成组合索引
+	tagKeyIndex = "index"
+	// tagKeyUnique 唯一索引，用法和 index 一样
+	tagKeyUnique = "unique"
 )
 
+// tagValueKeys 必须用 key=value 形式的标签，
+// 其余的标签都可以只写 key
+var tagValueKeys = map[string]struct{}{
+	tagKeyColumn:  {},
+	tagKeyDefault: {},
+	tagKeyType:    {},
+	tagKeySize:    {},
+	// many_to_many 的值是中间表的表名
+	tagKeyManyToMany:     {},
+	tagKeyForeignKey:     {},
+	tagKeyReferences:     {},
+	tagKeyJoinForeignKey: {},
+	tagKeyJoinReferences: {},
+}
+
 // 用户自定义一些模型信息的接口，集中放在这里
 // 方便用户查找和我们后期维护
 
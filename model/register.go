@@ -1,10 +1,14 @@
 package model
 
 import (
+	"database/sql"
+	"database/sql/driver"
 	"orm/internal/errs"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 	"unicode"
 )
 
@@ -110,18 +114,20 @@ func (r *registry) parseTag(tag reflect.StructTag) (map[string]string, error) {
 		// 返回一个空的 map，这样调用者就不需要判断 nil 了
 		return map[string]string{}, nil
 	}
-	// 这个初始化容量就是支持的 key 的数量，
-	// 现在只有一个，所以我们初始化为 1
-	res := make(map[string]string, 1)
+	// 这个初始化容量就是支持的 key 的数量
+	res := make(map[string]string, 8)
 
 	// 接下来就是字符串处理了
 	pairs := strings.Split(ormTag, ",")
 	for _, pair := range pairs {
-		kv := strings.Split(pair, "=")
-		if len(kv) != 2 {
-			return nil, errs.NewErrInvalidTagContent(pair)
+		key, val, found := strings.Cut(pair, "=")
+		if !found {
+			// 类似于 primary_key 这种只有 key 的标签
+			if _, ok := tagValueKeys[key]; ok {
+				return nil, errs.NewErrInvalidTagContent(pair)
+			}
 		}
-		res[kv[0]] = kv[1]
+		res[key] = val
 	}
 	return res, nil
 }
@@ -131,55 +137,369 @@ func (r *registry) parseModel(val any) (*Model, error) {
 	if typ.Kind() != reflect.Ptr || typ.Elem().Kind() != reflect.Struct {
 		return nil, errs.ErrPointerOnly
 	}
-
 	typ = typ.Elem()
 
-	// 获得字段的数量
+	metas, err := r.parseFields(typ, fieldScope{})
+	if err != nil {
+		return nil, err
+	}
+	// 和 Go 提升字段的规则一样，同名字段只保留层级最浅的那个
+	depths := make(map[string]int, len(metas))
+	for _, m := range metas {
+		if d, ok := depths[m.goName()]; !ok || m.depth < d {
+			depths[m.goName()] = m.depth
+		}
+	}
+	fields := make([]*Field, 0, len(metas))
+	fds := make(map[string]*Field, len(metas))
+	colMap := make(map[string]*Field, len(metas))
+	var indexes []*Index
+	var pks []*Field
+	var relations map[string]*Relation
+	var parts []*Part
+	for _, m := range metas {
+		if m.depth != depths[m.goName()] {
+			continue
+		}
+		if _, ok := fds[m.goName()]; ok {
+			return nil, errs.NewErrAmbiguousField(m.goName())
+		}
+		if _, ok := relations[m.goName()]; ok {
+			return nil, errs.NewErrAmbiguousField(m.goName())
+		}
+		if m.part != nil {
+			parts = append(parts, m.part)
+			continue
+		}
+		if m.rel != nil {
+			if relations == nil {
+				relations = make(map[string]*Relation, 4)
+			}
+			setRelationDefaults(typ, m.rel)
+			relations[m.rel.GoName] = m.rel
+			continue
+		}
+		fdMeta := m.fd
+		if _, ok := colMap[fdMeta.ColName]; ok {
+			return nil, errs.NewErrDuplicateColumn(fdMeta.ColName)
+		}
+		if fdMeta.PrimaryKey {
+			pks = append(pks, fdMeta)
+		}
+		indexes = r.parseIndexTags(indexes, fdMeta, m.tags)
+		fds[fdMeta.GoName] = fdMeta
+		colMap[fdMeta.ColName] = fdMeta
+		fields = append(fields, fdMeta)
+	}
+	var tableName string
+	if tn, ok := val.(TableName); ok {
+		tableName = tn.TableName()
+	}
+	// 注意: 极端情况在 tn.TableName() 中表名有可能被自定义为 "", 这时将使用默认格式的表名
+	if tableName == "" {
+		tableName = underscoreName(typ.Name())
+	}
+	return &Model{
+		Type:        typ,
+		TableName:   tableName,
+		FieldMap:    fds,
+		ColumnMap:   colMap,
+		Fields:      fields,
+		PrimaryKeys: pks,
+		Indexes:     indexes,
+		Relations:   relations,
+		Parts:       parts,
+	}, nil
+}
+
+// fieldScope 解析嵌套结构体时，外层结构体带下来的信息
+type fieldScope struct {
+	// index 外层结构体的索引路径
+	index []int
+	// offset 外层结构体相对于模型起始地址的偏移量
+	offset uintptr
+	// prefix 列名前缀，多层嵌套的时候会叠加
+	prefix string
+	// goPrefix 具名嵌套结构体的字段名前缀，例如 Addr.
+	goPrefix string
+	depth    int
+}
+
+// fieldMeta 是列，关联关系或者组合结构体的一部分，三者只有一个不为 nil
+type fieldMeta struct {
+	fd    *Field
+	rel   *Relation
+	part  *Part
+	tags  map[string]string
+	depth int
+}
+
+func (f fieldMeta) goName() string {
+	switch {
+	case f.rel != nil:
+		return f.rel.GoName
+	case f.part != nil:
+		return f.part.GoName
+	default:
+		return f.fd.GoName
+	}
+}
+
+// parseFields 按照声明顺序解析字段。
+// 匿名嵌入的结构体，以及带有 prefix 标签的结构体字段会被展开，
+// 其余的字段，包括 time.Time 和 sql.NullXXX 这种结构体，都对应一个列
+func (r *registry) parseFields(typ reflect.Type, scope fieldScope) ([]fieldMeta, error) {
 	numField := typ.NumField()
-	fields := make([]*Field, 0, numField)
-	fds := make(map[string]*Field, numField)
-	colMap := make(map[string]*Field, numField)
+	res := make([]fieldMeta, 0, numField)
 	for i := 0; i < numField; i++ {
 		fdType := typ.Field(i)
-
 		tags, err := r.parseTag(fdType.Tag)
 		if err != nil {
 			return nil, err
 		}
+		if _, ok := tags[tagKeyIgnore]; ok {
+			continue
+		}
+		index := make([]int, len(scope.index), len(scope.index)+1)
+		copy(index, scope.index)
+		index = append(index, i)
+		offset := scope.offset + fdType.Offset
+
+		if alias, ok := tags[tagKeyAlias]; ok {
+			if !isNestedStruct(fdType.Type) {
+				return nil, errs.NewErrInvalidTagContent(fdType.Name + " " + tagKeyAlias)
+			}
+			// 注意这里不能调用 Get，因为 Get 在调用 parseModel 的时候持有锁
+			m, err := r.parseModel(reflect.New(fdType.Type).Interface())
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, fieldMeta{part: &Part{
+				GoName: scope.goPrefix + fdType.Name,
+				Alias:  alias,
+				Index:  index,
+				Offset: offset,
+				Model:  m,
+			}, tags: tags, depth: scope.depth})
+			continue
+		}
+
+		if kind, ok := relationKind(tags); ok {
+			rel, err := parseRelation(kind, fdType, tags)
+			if err != nil {
+				return nil, err
+			}
+			rel.GoName = scope.goPrefix + fdType.Name
+			rel.Index = index
+			res = append(res, fieldMeta{rel: rel, tags: tags, depth: scope.depth})
+			continue
+		}
+
+		prefix, hasPrefix := tags[tagKeyPrefix]
+		if hasPrefix || fdType.Anonymous {
+			if fdType.Type.Kind() == reflect.Ptr && isNestedStruct(fdType.Type.Elem()) {
+				return nil, errs.NewErrEmbeddedPointer(scope.goPrefix + fdType.Name)
+			}
+			if isNestedStruct(fdType.Type) {
+				goPrefix := scope.goPrefix
+				if !fdType.Anonymous {
+					goPrefix = goPrefix + fdType.Name + "."
+				}
+				sub, err := r.parseFields(fdType.Type, fieldScope{
+					index:    index,
+					offset:   offset,
+					prefix:   scope.prefix + prefix,
+					goPrefix: goPrefix,
+					depth:    scope.depth + 1,
+				})
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, sub...)
+				continue
+			}
+			if hasPrefix {
+				return nil, errs.NewErrInvalidTagContent(tagKeyPrefix + "=" + prefix)
+			}
+		}
+
 		colName := tags[tagKeyColumn]
 		if colName == "" {
 			colName = underscoreName(fdType.Name)
 		}
-		fdName := fdType.Name
 		fdMeta := &Field{
-			ColName: colName,
+			ColName: scope.prefix + colName,
 			Type:    fdType.Type,
-			GoName:  fdName,
-			Offset:  fdType.Offset,
-			Index:   i,
+			GoName:  scope.goPrefix + fdType.Name,
+			Offset:  offset,
+			Index:   index,
 		}
-		fds[fdName] = fdMeta
-		colMap[colName] = fdMeta
-		fields = append(fields, fdMeta)
+		if err = r.parseFieldTags(fdMeta, tags); err != nil {
+			return nil, err
+		}
+		res = append(res, fieldMeta{fd: fdMeta, tags: tags, depth: scope.depth})
 	}
-	var tableName string
-	if tn, ok := val.(TableName); ok {
-		tableName = tn.TableName()
+	return res, nil
+}
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	valuerType  = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+)
+
+// isNestedStruct 判断结构体是否需要展开。
+// time.Time 和实现了 sql.Scanner 或者 driver.Valuer 的结构体本身就是一个列。
+// 注意嵌入结构体指针是不支持展开的，因为没办法通过偏移量访问，parseFields 会返回错误
+func isNestedStruct(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || typ == timeType {
+		return false
 	}
-	// 注意: 极端情况在 tn.TableName() 中表名有可能被自定义为 "", 这时将使用默认格式的表名
-	if tableName == "" {
-		tableName = underscoreName(typ.Name())
+	return !reflect.PointerTo(typ).Implements(scannerType) && !typ.Implements(valuerType)
+}
+
+var relationKinds = []struct {
+	key  string
+	kind RelationKind
+}{
+	{key: tagKeyHasOne, kind: HasOne},
+	{key: tagKeyHasMany, kind: HasMany},
+	{key: tagKeyBelongsTo, kind: BelongsTo},
+	{key: tagKeyManyToMany, kind: ManyToMany},
+}
+
+func relationKind(tags map[string]string) (RelationKind, bool) {
+	for _, rk := range relationKinds {
+		if _, ok := tags[rk.key]; ok {
+			return rk.kind, true
+		}
 	}
+	return 0, false
+}
 
-	return &Model{
-		Type:      typ,
-		TableName: tableName,
-		FieldMap:  fds,
-		ColumnMap: colMap,
-		Fields:    fields,
+// parseRelation 解析关联字段。
+// HasMany 和 ManyToMany 的字段必须是结构体切片或者结构体指针切片，
+// HasOne 和 BelongsTo 的字段必须是结构体或者结构体指针
+func parseRelation(kind RelationKind, fdType reflect.StructField, tags map[string]string) (*Relation, error) {
+	elem := fdType.Type
+	many := kind == HasMany || kind == ManyToMany
+	if many {
+		if elem.Kind() != reflect.Slice {
+			return nil, errs.NewErrInvalidTagContent(fdType.Name + " " + relationKinds[kind-1].key)
+		}
+		elem = elem.Elem()
+	}
+	if elem.Kind() == reflect.Ptr {
+		elem = elem.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil, errs.NewErrInvalidTagContent(fdType.Name + " " + relationKinds[kind-1].key)
+	}
+	return &Relation{
+		Kind:           kind,
+		Type:           fdType.Type,
+		Elem:           elem,
+		ForeignKey:     tags[tagKeyForeignKey],
+		References:     tags[tagKeyReferences],
+		JoinTable:      tags[tagKeyManyToMany],
+		JoinForeignKey: tags[tagKeyJoinForeignKey],
+		JoinReferences: tags[tagKeyJoinReferences],
 	}, nil
 }
 
+// setRelationDefaults 填充没有通过标签指定的外键
+func setRelationDefaults(owner reflect.Type, rel *Relation) {
+	switch rel.Kind {
+	case HasOne, HasMany:
+		if rel.ForeignKey == "" {
+			rel.ForeignKey = owner.Name() + "Id"
+		}
+	case BelongsTo:
+		if rel.ForeignKey == "" {
+			name := rel.GoName[strings.LastIndexByte(rel.GoName, '.')+1:]
+			rel.ForeignKey = name + "Id"
+		}
+	case ManyToMany:
+		if rel.JoinForeignKey == "" {
+			rel.JoinForeignKey = underscoreName(owner.Name()) + "_id"
+		}
+		if rel.JoinReferences == "" {
+			rel.JoinReferences = underscoreName(rel.Elem.Name()) + "_id"
+		}
+	}
+}
+
+// parseFieldTags 解析 DDL 相关的标签
+func (r *registry) parseFieldTags(fd *Field, tags map[string]string) error {
+	_, pk := tags[tagKeyPrimaryKey]
+	_, short := tags[tagKeyPK]
+	fd.PrimaryKey = pk || short
+	_, fd.AutoIncrement = tags[tagKeyAutoIncrement]
+	_, nullable := tags[tagKeyNullable]
+	_, notNull := tags[tagKeyNotNull]
+	switch {
+	case nullable && notNull:
+		return errs.NewErrInvalidTagContent(tagKeyNullable + "," + tagKeyNotNull)
+	case nullable:
+		fd.Nullable = true
+	case notNull:
+		fd.Nullable = false
+	default:
+		fd.Nullable = nullableType(fd.Type)
+	}
+	fd.Default = tags[tagKeyDefault]
+	fd.SQLType = tags[tagKeyType]
+	if size, ok := tags[tagKeySize]; ok {
+		val, err := strconv.Atoi(size)
+		if err != nil || val <= 0 {
+			return errs.NewErrInvalidTagContent(tagKeySize + "=" + size)
+		}
+		fd.Size = val
+	}
+	return nil
+}
+
+// parseIndexTags 解析 index 和 unique 标签，同名的索引会合并为组合索引
+func (r *registry) parseIndexTags(indexes []*Index, fd *Field, tags map[string]string) []*Index {
+	for _, key := range []string{tagKeyIndex, tagKeyUnique} {
+		name, ok := tags[key]
+		if !ok {
+			continue
+		}
+		unique := key == tagKeyUnique
+		var merged bool
+		if name != "" {
+			for _, idx := range indexes {
+				if idx.Name == name && idx.Unique == unique {
+					idx.Fields = append(idx.Fields, fd)
+					merged = true
+					break
+				}
+			}
+		}
+		if !merged {
+			indexes = append(indexes, &Index{
+				Name:   name,
+				Unique: unique,
+				Fields: []*Field{fd},
+			})
+		}
+	}
+	return indexes
+}
+
+// nullableType 指针，切片，map 和 sql.NullXXX 这种类型默认允许 NULL
+func nullableType(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+		return true
+	case reflect.Struct:
+		return typ.PkgPath() == "database/sql" && strings.HasPrefix(typ.Name(), "Null")
+	default:
+		return false
+	}
+}
+
 // underscoreName 驼峰转字符串命名
 func underscoreName(tableName string) string {
 	var buf []byte
@@ -4,8 +4,10 @@ import (
 	"database/sql"
 	"github.com/stretchr/testify/assert"
 	"orm/internal/errs"
+	"orm/internal/test"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestWithColumnName(t *testing.T) {
@@ -74,6 +76,7 @@ func TestRegistry_get(t *testing.T) {
 			val:  &TestModel{},
 			wantModel: &Model{
 				TableName: "test_model",
+				Type:      reflect.TypeOf(TestModel{}),
 				Fields:    []*Field{tm.IdField(), tm.FirstNameField(), tm.AgeField(), tm.LastNameField()},
 				FieldMap: map[string]*Field{
 					"Id":        tm.IdField(),
@@ -89,6 +92,308 @@ func TestRegistry_get(t *testing.T) {
 				},
 			},
 		},
+		{
+			// DDL 相关的标签
+			name: "ddl tags",
+			val: func() any {
+				type DDLTag struct {
+					Id      int64  `orm:"primary_key,auto_increment"`
+					Name    string `orm:"column=user_name,unique,default='Tom'"`
+					Email   *string
+					Age     *int   `orm:"not_null,index"`
+					Address string `orm:"type=varchar(128),index=idx_addr_city"`
+					City    string `orm:"index=idx_addr_city"`
+				}
+				return &DDLTag{}
+			}(),
+			wantModel: func() *Model {
+				id := &Field{ColName: "id", GoName: "Id", Type: reflect.TypeOf(int64(0)),
+					Offset: 0, Index: []int{0}, PrimaryKey: true, AutoIncrement: true}
+				name := &Field{ColName: "user_name", GoName: "Name", Type: reflect.TypeOf(""),
+					Offset: 8, Index: []int{1}, Default: "'Tom'"}
+				email := &Field{ColName: "email", GoName: "Email", Type: reflect.TypeOf(new(string)),
+					Offset: 24, Index: []int{2}, Nullable: true}
+				age := &Field{ColName: "age", GoName: "Age", Type: reflect.TypeOf(new(int)),
+					Offset: 32, Index: []int{3}}
+				address := &Field{ColName: "address", GoName: "Address", Type: reflect.TypeOf(""),
+					Offset: 40, Index: []int{4}, SQLType: "varchar(128)"}
+				city := &Field{ColName: "city", GoName: "City", Type: reflect.TypeOf(""),
+					Offset: 56, Index: []int{5}}
+				fields := []*Field{id, name, email, age, address, city}
+				m := &Model{
+					TableName:   "d_d_l_tag",
+					Fields:      fields,
+					PrimaryKeys: []*Field{id},
+					FieldMap:    make(map[string]*Field, len(fields)),
+					ColumnMap:   make(map[string]*Field, len(fields)),
+					Indexes: []*Index{
+						{Unique: true, Fields: []*Field{name}},
+						{Fields: []*Field{age}},
+						{Name: "idx_addr_city", Fields: []*Field{address, city}},
+					},
+				}
+				for _, fd := range fields {
+					m.FieldMap[fd.GoName] = fd
+					m.ColumnMap[fd.ColName] = fd
+				}
+				return m
+			}(),
+		},
+		{
+			// pk 简写和自增主键一起使用
+			name: "pk auto_increment",
+			val: func() any {
+				type AutoIncrementTag struct {
+					Id   uint64 `orm:"pk,auto_increment"`
+					Name string
+				}
+				return &AutoIncrementTag{}
+			}(),
+			wantModel: func() *Model {
+				id := &Field{ColName: "id", GoName: "Id", Type: reflect.TypeOf(uint64(0)),
+					Offset: 0, Index: []int{0}, PrimaryKey: true, AutoIncrement: true}
+				name := &Field{ColName: "name", GoName: "Name", Type: reflect.TypeOf(""),
+					Offset: 8, Index: []int{1}}
+				fields := []*Field{id, name}
+				m := &Model{
+					TableName:   "auto_increment_tag",
+					Fields:      fields,
+					PrimaryKeys: []*Field{id},
+					FieldMap:    make(map[string]*Field, len(fields)),
+					ColumnMap:   make(map[string]*Field, len(fields)),
+				}
+				for _, fd := range fields {
+					m.FieldMap[fd.GoName] = fd
+					m.ColumnMap[fd.ColName] = fd
+				}
+				return m
+			}(),
+		},
+		{
+			// pk 简写，组合主键，忽略字段，nullable 和 size
+			name: "pk ignore nullable size",
+			val: func() any {
+				type PKTag struct {
+					UserId  int64  `orm:"pk"`
+					OrderId int64  `orm:"primary_key"`
+					Cache   []byte `orm:"-"`
+					Remark  string `orm:"nullable,size=255"`
+					Score   *int   `orm:"not_null"`
+				}
+				return &PKTag{}
+			}(),
+			wantModel: func() *Model {
+				userId := &Field{ColName: "user_id", GoName: "UserId", Type: reflect.TypeOf(int64(0)),
+					Offset: 0, Index: []int{0}, PrimaryKey: true}
+				orderId := &Field{ColName: "order_id", GoName: "OrderId", Type: reflect.TypeOf(int64(0)),
+					Offset: 8, Index: []int{1}, PrimaryKey: true}
+				remark := &Field{ColName: "remark", GoName: "Remark", Type: reflect.TypeOf(""),
+					Offset: 40, Index: []int{3}, Nullable: true, Size: 255}
+				score := &Field{ColName: "score", GoName: "Score", Type: reflect.TypeOf(new(int)),
+					Offset: 56, Index: []int{4}}
+				fields := []*Field{userId, orderId, remark, score}
+				m := &Model{
+					TableName:   "p_k_tag",
+					Fields:      fields,
+					PrimaryKeys: []*Field{userId, orderId},
+					FieldMap:    make(map[string]*Field, len(fields)),
+					ColumnMap:   make(map[string]*Field, len(fields)),
+				}
+				for _, fd := range fields {
+					m.FieldMap[fd.GoName] = fd
+					m.ColumnMap[fd.ColName] = fd
+				}
+				return m
+			}(),
+		},
+		{
+			// 匿名嵌入的结构体和带前缀的结构体字段都会被展开
+			name: "embedded and prefix",
+			val:  &test.EmbeddedStruct{},
+			wantModel: func() *Model {
+				id := &Field{ColName: "id", GoName: "Id", Type: reflect.TypeOf(uint64(0)),
+					Offset: 0, Index: []int{0, 0}, PrimaryKey: true, AutoIncrement: true}
+				createdAt := &Field{ColName: "created_at", GoName: "CreatedAt", Type: reflect.TypeOf(int64(0)),
+					Offset: 8, Index: []int{0, 1}}
+				updatedAt := &Field{ColName: "updated_at", GoName: "UpdatedAt", Type: reflect.TypeOf(int64(0)),
+					Offset: 16, Index: []int{0, 2}}
+				name := &Field{ColName: "name", GoName: "Name", Type: reflect.TypeOf(""),
+					Offset: 24, Index: []int{1}}
+				city := &Field{ColName: "addr_city", GoName: "Addr.City", Type: reflect.TypeOf(""),
+					Offset: 40, Index: []int{2, 0}}
+				street := &Field{ColName: "addr_street", GoName: "Addr.Street", Type: reflect.TypeOf(""),
+					Offset: 56, Index: []int{2, 1}}
+				fields := []*Field{id, createdAt, updatedAt, name, city, street}
+				m := &Model{
+					TableName:   "embedded_struct",
+					Type:        reflect.TypeOf(test.EmbeddedStruct{}),
+					Fields:      fields,
+					PrimaryKeys: []*Field{id},
+					FieldMap:    make(map[string]*Field, len(fields)),
+					ColumnMap:   make(map[string]*Field, len(fields)),
+				}
+				for _, fd := range fields {
+					m.FieldMap[fd.GoName] = fd
+					m.ColumnMap[fd.ColName] = fd
+				}
+				return m
+			}(),
+		},
+		{
+			// 外层的同名字段会覆盖嵌入结构体里面的字段，time.Time 不会被展开
+			name: "shadowed field",
+			val: func() any {
+				type Shadow struct {
+					test.BaseModel
+					Id        string
+					DeletedAt time.Time
+				}
+				return &Shadow{}
+			}(),
+			wantModel: func() *Model {
+				createdAt := &Field{ColName: "created_at", GoName: "CreatedAt", Type: reflect.TypeOf(int64(0)),
+					Offset: 8, Index: []int{0, 1}}
+				updatedAt := &Field{ColName: "updated_at", GoName: "UpdatedAt", Type: reflect.TypeOf(int64(0)),
+					Offset: 16, Index: []int{0, 2}}
+				id := &Field{ColName: "id", GoName: "Id", Type: reflect.TypeOf(""),
+					Offset: 24, Index: []int{1}}
+				deletedAt := &Field{ColName: "deleted_at", GoName: "DeletedAt", Type: reflect.TypeOf(time.Time{}),
+					Offset: 40, Index: []int{2}}
+				fields := []*Field{createdAt, updatedAt, id, deletedAt}
+				m := &Model{
+					TableName: "shadow",
+					Fields:    fields,
+					FieldMap:  make(map[string]*Field, len(fields)),
+					ColumnMap: make(map[string]*Field, len(fields)),
+				}
+				for _, fd := range fields {
+					m.FieldMap[fd.GoName] = fd
+					m.ColumnMap[fd.ColName] = fd
+				}
+				return m
+			}(),
+		},
+		{
+			// 关联字段不是列
+			name: "relations",
+			val: func() any {
+				type Tag struct{}
+				type Order struct{}
+				type Profile struct{}
+				type User struct {
+					Id      int64
+					Profile Profile  `orm:"has_one"`
+					Orders  []*Order `orm:"has_many,foreign_key=BuyerId,references=Id"`
+					Tags    []Tag    `orm:"many_to_many=user_tag,join_references=tid"`
+					Inviter *User    `orm:"belongs_to"`
+				}
+				return &User{}
+			}(),
+			wantModel: func() *Model {
+				id := &Field{ColName: "id", GoName: "Id", Type: reflect.TypeOf(int64(0)),
+					Offset: 0, Index: []int{0}}
+				return &Model{
+					TableName: "user",
+					Fields:    []*Field{id},
+					FieldMap:  map[string]*Field{"Id": id},
+					ColumnMap: map[string]*Field{"id": id},
+					Relations: map[string]*Relation{
+						"Profile": {Kind: HasOne, GoName: "Profile", Index: []int{1}, ForeignKey: "UserId"},
+						"Orders": {Kind: HasMany, GoName: "Orders", Index: []int{2},
+							ForeignKey: "BuyerId", References: "Id"},
+						"Tags": {Kind: ManyToMany, GoName: "Tags", Index: []int{3},
+							JoinTable: "user_tag", JoinForeignKey: "user_id", JoinReferences: "tid"},
+						"Inviter": {Kind: BelongsTo, GoName: "Inviter", Index: []int{4}, ForeignKey: "InviterId"},
+					},
+				}
+			}(),
+		},
+		{
+			name: "invalid relation",
+			val: func() any {
+				type InvalidRelation struct {
+					Orders int64 `orm:"has_many"`
+				}
+				return &InvalidRelation{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("Orders has_many"),
+		},
+		{
+			name: "duplicate column",
+			val: func() any {
+				type DuplicateColumn struct {
+					test.BaseModel
+					Created int64 `orm:"column=created_at"`
+				}
+				return &DuplicateColumn{}
+			}(),
+			wantErr: errs.NewErrDuplicateColumn("created_at"),
+		},
+		{
+			// prefix 只能用在结构体上
+			name: "invalid prefix",
+			val: func() any {
+				type InvalidPrefix struct {
+					Name string `orm:"prefix=p_"`
+				}
+				return &InvalidPrefix{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("prefix=p_"),
+		},
+		{
+			// 嵌入的结构体指针没办法展开
+			name: "embedded pointer",
+			val: func() any {
+				type EmbeddedPointer struct {
+					*test.BaseModel
+					Name string
+				}
+				return &EmbeddedPointer{}
+			}(),
+			wantErr: errs.NewErrEmbeddedPointer("BaseModel"),
+		},
+		{
+			name: "prefix pointer",
+			val: func() any {
+				type PrefixPointer struct {
+					Id    int64
+					Audit *test.BaseModel `orm:"prefix=audit_"`
+				}
+				return &PrefixPointer{}
+			}(),
+			wantErr: errs.NewErrEmbeddedPointer("Audit"),
+		},
+		{
+			name: "invalid size",
+			val: func() any {
+				type InvalidSize struct {
+					Remark string `orm:"size=abc"`
+				}
+				return &InvalidSize{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("size=abc"),
+		},
+		{
+			name: "nullable and not_null",
+			val: func() any {
+				type InvalidNullable struct {
+					Remark string `orm:"nullable,not_null"`
+				}
+				return &InvalidNullable{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("nullable,not_null"),
+		},
+		{
+			// column 必须有值
+			name: "invalid tag",
+			val: func() any {
+				type InvalidTag struct {
+					FirstName string `orm:"column"`
+				}
+				return &InvalidTag{}
+			}(),
+			wantErr: errs.NewErrInvalidTagContent("column"),
+		},
 		//{
 		//	// 多级指针
 		//	name: "multiple pointer",
@@ -316,6 +621,15 @@ func TestRegistry_get(t *testing.T) {
 			if err != nil {
 				return
 			}
+			if tc.wantModel.Type == nil {
+				// 方法内部定义的类型没办法在用例里面直接引用
+				tc.wantModel.Type = m.Type
+			}
+			for name, rel := range tc.wantModel.Relations {
+				// 同上，关联字段的类型直接使用解析的结果
+				rel.Type = m.Relations[name].Type
+				rel.Elem = m.Relations[name].Elem
+			}
 			assert.Equal(t, tc.wantModel, m)
 		})
 	}
@@ -390,7 +704,7 @@ func (TestModel) IdField() *Field {
 		Type:    reflect.TypeOf(int64(0)),
 		GoName:  "Id",
 		Offset:  0,
-		Index:   0,
+		Index:   []int{0},
 	}
 }
 
@@ -400,7 +714,7 @@ func (TestModel) FirstNameField() *Field {
 		Type:    reflect.TypeOf(""),
 		GoName:  "FirstName",
 		Offset:  8,
-		Index:   1,
+		Index:   []int{1},
 	}
 }
 
@@ -410,16 +724,17 @@ func (TestModel) AgeField() *Field {
 		Type:    reflect.TypeOf(int8(0)),
 		GoName:  "Age",
 		Offset:  24,
-		Index:   2,
+		Index:   []int{2},
 	}
 }
 
 func (TestModel) LastNameField() *Field {
 	return &Field{
-		ColName: "last_name",
-		Type:    reflect.TypeOf(&sql.NullString{}),
-		GoName:  "LastName",
-		Offset:  32,
-		Index:   3,
+		ColName:  "last_name",
+		Type:     reflect.TypeOf(&sql.NullString{}),
+		GoName:   "LastName",
+		Offset:   32,
+		Index:    []int{3},
+		Nullable: true,
 	}
 }
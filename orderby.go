@@ -3,23 +3,51 @@ package orm
 const (
 	asc  = "ASC"
 	desc = "DESC"
+
+	nullsFirst = "NULLS FIRST"
+	nullsLast  = "NULLS LAST"
 )
 
 type OrderBy struct {
-	col   string
+	expr  Expression
 	order string
+	// nulls NULL 值排在前面还是后面，为空的时候使用数据库的默认行为
+	nulls string
 }
 
-func Asc(col string) OrderBy {
+// Asc 升序，col 可以是字段名或者 SELECT 里面的别名，
+// 也可以是 Expression，例如 Asc(Lower(C("FirstName")))、Asc(t.C("Id"))、Asc(Avg("Age"))
+func Asc(col any) OrderBy {
 	return OrderBy{
-		col:   col,
+		expr:  orderByExpr(col),
 		order: asc,
 	}
 }
 
-func Desc(col string) OrderBy {
+// Desc 降序，col 的用法和 Asc 一样
+func Desc(col any) OrderBy {
 	return OrderBy{
-		col:   col,
+		expr:  orderByExpr(col),
 		order: desc,
 	}
 }
+
+// NullsFirst NULL 值排在最前面。
+// 不支持 NULLS FIRST 的数据库，例如 MySQL，会使用 CASE WHEN 来模拟
+func (o OrderBy) NullsFirst() OrderBy {
+	o.nulls = nullsFirst
+	return o
+}
+
+// NullsLast NULL 值排在最后面
+func (o OrderBy) NullsLast() OrderBy {
+	o.nulls = nullsLast
+	return o
+}
+
+func orderByExpr(col any) Expression {
+	if name, ok := col.(string); ok {
+		return C(name)
+	}
+	return exprOf(col)
+}
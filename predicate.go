@@ -1,5 +1,10 @@
 package orm
 
+import (
+	"database/sql/driver"
+	"reflect"
+)
+
 type predicates struct {
 	ps            []Predicate
 	useColsAlias  bool
@@ -51,3 +56,72 @@ func (p Predicate) Or(r Predicate) Predicate {
 		right: r,
 	}
 }
+
+// newPredicate 构造 left op right 形式的谓词
+func newPredicate(left Expression, o op, right any) Predicate {
+	return Predicate{
+		left:  left,
+		op:    o,
+		right: exprOf(right),
+	}
+}
+
+// newNullPredicate 构造 left IS NULL 或者 left IS NOT NULL，这种谓词没有右边
+func newNullPredicate(left Expression, o op) Predicate {
+	return Predicate{
+		left: left,
+		op:   o,
+	}
+}
+
+// newBetween 构造 left BETWEEN lo AND hi，
+// 右边是 lo AND hi，构造的时候不会加括号
+func newBetween(left Expression, lo, hi any) Predicate {
+	return Predicate{
+		left: left,
+		op:   opBetween,
+		right: binaryExpr{
+			left:  exprOf(lo),
+			op:    opAND,
+			right: exprOf(hi),
+		},
+	}
+}
+
+// newIn 构造 IN 和 NOT IN。
+// 只有一个参数的时候，如果它是 Expression，例如子查询，那么直接使用；
+// 如果它是切片或者数组，那么展开成 (?,?,?)。
+// 注意 []byte 和实现了 driver.Valuer 的切片会被当成一个值
+func newIn(left Expression, o op, vals []any) Predicate {
+	if len(vals) == 1 {
+		switch val := vals[0].(type) {
+		case Expression:
+			return Predicate{left: left, op: o, right: val}
+		default:
+			if list, ok := expandSlice(val); ok {
+				vals = list
+			}
+		}
+	}
+	return Predicate{
+		left:  left,
+		op:    o,
+		right: inValues{vals: vals},
+	}
+}
+
+func expandSlice(val any) ([]any, bool) {
+	switch val.(type) {
+	case []byte, driver.Valuer:
+		return nil, false
+	}
+	rv := reflect.ValueOf(val)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	res := make([]any, rv.Len())
+	for i := 0; i < rv.Len(); i++ {
+		res[i] = rv.Index(i).Interface()
+	}
+	return res, true
+}
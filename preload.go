@@ -0,0 +1,376 @@
+package orm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"math"
+	"orm/internal/errs"
+	"orm/model"
+	"reflect"
+)
+
+// Preload 在 Get 和 GetMulti 查询到数据之后，
+// 为每一个关联关系执行一次批量查询，例如 WHERE `user_id` IN (...)，
+// 然后把结果填充到关联字段里面，从而避免 N+1 查询。
+// name 是关联字段的名字，例如 Orders
+func (s *Selector[T]) Preload(names ...string) *Selector[T] {
+	s.preloads = append(s.preloads, names...)
+	return s
+}
+
+func (s *Selector[T]) preload(ctx context.Context, res []*T) error {
+	if len(s.preloads) == 0 || len(res) == 0 {
+		return nil
+	}
+	// s.model 有可能是 From 指定的表，所以这里重新获取 T 的元数据
+	owner, err := s.r.Get(new(T))
+	if err != nil {
+		return err
+	}
+	parents := make([]reflect.Value, 0, len(res))
+	for _, r := range res {
+		parents = append(parents, reflect.ValueOf(r).Elem())
+	}
+	for _, name := range s.preloads {
+		rel, ok := owner.Relations[name]
+		if !ok {
+			return errs.NewErrUnknownRelation(name)
+		}
+		p := &preloader{core: s.core, sess: s.sess, owner: owner, rel: rel}
+		if err = p.load(ctx, parents); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// preloader 加载一个关联关系
+type preloader struct {
+	core
+	sess  session
+	owner *model.Model
+	rel   *model.Relation
+}
+
+func (p *preloader) load(ctx context.Context, parents []reflect.Value) error {
+	target, err := p.r.Get(reflect.New(p.rel.Elem).Interface())
+	if err != nil {
+		return err
+	}
+	switch p.rel.Kind {
+	case model.HasOne, model.HasMany:
+		ownerKey, err := referenceField(p.owner, p.rel.References)
+		if err != nil {
+			return err
+		}
+		fk, ok := target.FieldMap[p.rel.ForeignKey]
+		if !ok {
+			return errs.NewErrUnknownField(p.rel.ForeignKey)
+		}
+		return p.loadByKey(ctx, parents, ownerKey, target, fk)
+	case model.BelongsTo:
+		fk, ok := p.owner.FieldMap[p.rel.ForeignKey]
+		if !ok {
+			return errs.NewErrUnknownField(p.rel.ForeignKey)
+		}
+		targetKey, err := referenceField(target, p.rel.References)
+		if err != nil {
+			return err
+		}
+		return p.loadByKey(ctx, parents, fk, target, targetKey)
+	default:
+		return p.loadManyToMany(ctx, parents, target)
+	}
+}
+
+// loadByKey 查询 targetKey 的值等于 parentKey 的值的数据，并且填充到 parents 里面
+func (p *preloader) loadByKey(ctx context.Context, parents []reflect.Value,
+	parentKey *model.Field, target *model.Model, targetKey *model.Field) error {
+	keys := newKeySet(len(parents))
+	for _, parent := range parents {
+		keys.add(parent.FieldByIndex(parentKey.Index).Interface())
+	}
+	children, err := p.queryModels(ctx, target, targetKey, keys.vals)
+	if err != nil {
+		return err
+	}
+	groups := make(map[any][]reflect.Value, len(children))
+	for _, child := range children {
+		if key, ok := normalizeKey(child.Elem().FieldByIndex(targetKey.Index).Interface()); ok {
+			groups[key] = append(groups[key], child)
+		}
+	}
+	for _, parent := range parents {
+		key, _ := normalizeKey(parent.FieldByIndex(parentKey.Index).Interface())
+		p.set(parent, groups[key])
+	}
+	return nil
+}
+
+func (p *preloader) loadManyToMany(ctx context.Context, parents []reflect.Value, target *model.Model) error {
+	ownerKey, err := referenceField(p.owner, p.rel.References)
+	if err != nil {
+		return err
+	}
+	targetKey, err := referenceField(target, "")
+	if err != nil {
+		return err
+	}
+	keys := newKeySet(len(parents))
+	for _, parent := range parents {
+		keys.add(parent.FieldByIndex(ownerKey.Index).Interface())
+	}
+	if len(keys.vals) == 0 {
+		p.setAll(parents)
+		return nil
+	}
+
+	// 先查中间表，它没有对应的模型，所以这里构造一个只有两个键的元数据
+	joinFK, joinRef := *ownerKey, *targetKey
+	joinFK.GoName, joinFK.ColName = "From", p.rel.JoinForeignKey
+	joinRef.GoName, joinRef.ColName = "To", p.rel.JoinReferences
+	joinTable := &model.Model{
+		TableName: p.rel.JoinTable,
+		Fields:    []*model.Field{&joinFK, &joinRef},
+		FieldMap:  map[string]*model.Field{"From": &joinFK, "To": &joinRef},
+	}
+	pairs := make([][2]any, 0, len(keys.vals))
+	err = p.queryIn(ctx, joinTable, &joinFK, keys.vals, func(rows *sql.Rows) error {
+		// 使用两边的键的类型来接收，这样数据库驱动会帮我们完成类型转换
+		for rows.Next() {
+			from, to := reflect.New(ownerKey.Type), reflect.New(targetKey.Type)
+			if err := rows.Scan(from.Interface(), to.Interface()); err != nil {
+				return err
+			}
+			pairs = append(pairs, [2]any{from.Interface(), to.Interface()})
+		}
+		return rows.Err()
+	})
+	if err != nil {
+		return err
+	}
+	mapping := make(map[any][]any, len(keys.vals))
+	targetKeys := newKeySet(len(pairs))
+	for _, pair := range pairs {
+		from, ok1 := normalizeKey(pair[0])
+		to, ok2 := normalizeKey(pair[1])
+		if !ok1 || !ok2 {
+			continue
+		}
+		mapping[from] = append(mapping[from], to)
+		targetKeys.add(to)
+	}
+
+	// 再查关联的数据
+	children, err := p.queryModels(ctx, target, targetKey, targetKeys.vals)
+	if err != nil {
+		return err
+	}
+	byKey := make(map[any]reflect.Value, len(children))
+	for _, child := range children {
+		if key, ok := normalizeKey(child.Elem().FieldByIndex(targetKey.Index).Interface()); ok {
+			byKey[key] = child
+		}
+	}
+	for _, parent := range parents {
+		key, _ := normalizeKey(parent.FieldByIndex(ownerKey.Index).Interface())
+		tos := mapping[key]
+		matched := make([]reflect.Value, 0, len(tos))
+		for _, to := range tos {
+			if child, ok := byKey[to]; ok {
+				matched = append(matched, child)
+			}
+		}
+		p.set(parent, matched)
+	}
+	return nil
+}
+
+// queryModels 查询 key 的值在 vals 里面的 target，返回的是指针
+func (p *preloader) queryModels(ctx context.Context, target *model.Model,
+	key *model.Field, vals []any) ([]reflect.Value, error) {
+	res := make([]reflect.Value, 0, len(vals))
+	err := p.queryIn(ctx, target, key, vals, func(rows *sql.Rows) error {
+		for rows.Next() {
+			child := reflect.New(target.Type)
+			val := p.valCreator.NewBasicTypeValue(child.Interface(), target)
+			if err := val.SetColumns(rows); err != nil {
+				return err
+			}
+			res = append(res, child)
+		}
+		return rows.Err()
+	})
+	return res, err
+}
+
+// queryIn 查询 meta 的所有列，条件是 key 的值在 vals 里面。
+// vals 会按照 Dialect.MaxArgs 分批查询，每一批和其它查询一样会经过 Middleware
+func (p *preloader) queryIn(ctx context.Context, meta *model.Model, key *model.Field,
+	vals []any, scan func(rows *sql.Rows) error) error {
+	cols := make([]Selectable, 0, len(meta.Fields))
+	for _, fd := range meta.Fields {
+		cols = append(cols, C(fd.GoName))
+	}
+	size := p.dialect.MaxArgs()
+	if size <= 0 {
+		size = len(vals)
+	}
+	for start := 0; start < len(vals); start += size {
+		end := start + size
+		if end > len(vals) {
+			end = len(vals)
+		}
+		// meta 不一定是注册过的模型，所以直接设置，不从 FROM 里面解析
+		s := NewSelector[any](p.sess)
+		s.model = meta
+		s.Select(cols...).Where(C(key.GoName).In(vals[start:end]...))
+		if err := p.query(ctx, s, meta, scan); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// query 执行查询，和其它查询一样会经过 Middleware
+func (p *preloader) query(ctx context.Context, q QueryBuilder, meta *model.Model,
+	scan func(rows *sql.Rows) error) error {
+	var handler HandleFunc = func(ctx context.Context, qc *QueryContext) *QueryResult {
+		q, err := qc.Query()
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		rows, err := p.sess.queryContext(ctx, q.SQL, q.Args...)
+		if err != nil {
+			return &QueryResult{Err: err}
+		}
+		defer func() {
+			_ = rows.Close()
+		}()
+		return &QueryResult{Err: scan(rows)}
+	}
+	ms := p.ms
+	for i := len(ms) - 1; i >= 0; i-- {
+		handler = ms[i](handler)
+	}
+	return handler(ctx, &QueryContext{
+		Type:    "SELECT",
+		Builder: q,
+		Meta:    meta,
+	}).Err
+}
+
+// set 把 children 填充到 parent 的关联字段里面，
+// 关联字段是切片的时候，即便没有数据也会被设置为空切片
+func (p *preloader) set(parent reflect.Value, children []reflect.Value) {
+	fd := parent.FieldByIndex(p.rel.Index)
+	switch fd.Kind() {
+	case reflect.Slice:
+		ptr := fd.Type().Elem().Kind() == reflect.Ptr
+		res := reflect.MakeSlice(fd.Type(), 0, len(children))
+		for _, child := range children {
+			if !ptr {
+				child = child.Elem()
+			}
+			res = reflect.Append(res, child)
+		}
+		fd.Set(res)
+	case reflect.Ptr:
+		if len(children) > 0 {
+			fd.Set(children[0])
+		}
+	default:
+		if len(children) > 0 {
+			fd.Set(children[0].Elem())
+		}
+	}
+}
+
+func (p *preloader) setAll(parents []reflect.Value) {
+	for _, parent := range parents {
+		p.set(parent, nil)
+	}
+}
+
+// referenceField 返回被引用的字段，name 为空的时候使用第一个主键，没有主键就使用 Id
+func referenceField(m *model.Model, name string) (*model.Field, error) {
+	if name == "" {
+		if len(m.PrimaryKeys) > 0 {
+			return m.PrimaryKeys[0], nil
+		}
+		name = "Id"
+	}
+	fd, ok := m.FieldMap[name]
+	if !ok {
+		return nil, errs.NewErrUnknownField(name)
+	}
+	return fd, nil
+}
+
+// keySet 去重之后的键，保持第一次出现的顺序
+type keySet struct {
+	vals []any
+	seen map[any]struct{}
+}
+
+func newKeySet(capacity int) *keySet {
+	return &keySet{
+		vals: make([]any, 0, capacity),
+		seen: make(map[any]struct{}, capacity),
+	}
+}
+
+func (k *keySet) add(val any) {
+	key, ok := normalizeKey(val)
+	if !ok {
+		return
+	}
+	if _, ok = k.seen[key]; ok {
+		return
+	}
+	k.seen[key] = struct{}{}
+	k.vals = append(k.vals, key)
+}
+
+// normalizeKey 把键转换成可以比较的值。
+// 例如主键是 uint64，外键是 *int64，数据库驱动返回的是 int64 或者 []byte，
+// 它们都需要被转换成同一个类型才能匹配上。NULL 返回 false
+func normalizeKey(val any) (any, bool) {
+	rv := reflect.ValueOf(val)
+	for rv.IsValid() && rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, false
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, false
+	}
+	if valuer, ok := rv.Interface().(driver.Valuer); ok {
+		v, err := valuer.Value()
+		if err != nil || v == nil {
+			return nil, false
+		}
+		rv = reflect.ValueOf(v)
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if u := rv.Uint(); u <= math.MaxInt64 {
+			return int64(u), true
+		}
+		return rv.Uint(), true
+	case reflect.String:
+		return rv.String(), true
+	case reflect.Slice:
+		if rv.Type().Elem().Kind() == reflect.Uint8 {
+			return string(rv.Bytes()), true
+		}
+	}
+	if !rv.Type().Comparable() {
+		return nil, false
+	}
+	return rv.Interface(), true
+}
@@ -0,0 +1,188 @@
+package orm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"orm/internal/errs"
+	"testing"
+)
+
+type PreloadUser struct {
+	Id      int64 `orm:"pk"`
+	Name    string
+	Profile *PreloadProfile `orm:"has_one,foreign_key=UserId"`
+	Orders  []*PreloadOrder `orm:"has_many,foreign_key=UserId"`
+	Tags    []PreloadTag    `orm:"many_to_many=preload_user_tag"`
+}
+
+type PreloadProfile struct {
+	Id     int64 `orm:"pk"`
+	UserId int64
+	Bio    string
+}
+
+type PreloadOrder struct {
+	Id     int64 `orm:"pk"`
+	UserId *int64
+	Amount int
+	User   *PreloadUser `orm:"belongs_to"`
+}
+
+type PreloadTag struct {
+	Id   int64 `orm:"pk"`
+	Name string
+}
+
+func TestSelector_Preload(t *testing.T) {
+	ctx := context.Background()
+	var queries []string
+	db := memoryDBWithDB("preload", t)
+	db.ms = []Middleware{func(next HandleFunc) HandleFunc {
+		return func(ctx context.Context, qc *QueryContext) *QueryResult {
+			if qc.Type == "SELECT" {
+				q, err := qc.Query()
+				require.NoError(t, err)
+				queries = append(queries, q.SQL)
+			}
+			return next(ctx, qc)
+		}
+	}}
+	require.NoError(t, NewCreater[PreloadUser](db).Exec(ctx).Err())
+	require.NoError(t, NewCreater[PreloadProfile](db).Exec(ctx).Err())
+	require.NoError(t, NewCreater[PreloadOrder](db).Exec(ctx).Err())
+	require.NoError(t, NewCreater[PreloadTag](db).Exec(ctx).Err())
+	for _, s := range []string{
+		"CREATE TABLE `preload_user_tag` (`preload_user_id` integer,`preload_tag_id` integer);",
+		"INSERT INTO `preload_user`(`id`,`name`) VALUES (1,'Tom'),(2,'Jerry'),(3,'Spike');",
+		"INSERT INTO `preload_profile`(`id`,`user_id`,`bio`) VALUES (1,1,'cat'),(2,2,'mouse');",
+		"INSERT INTO `preload_order`(`id`,`user_id`,`amount`) VALUES (1,1,10),(2,1,20),(3,2,30),(4,NULL,40);",
+		"INSERT INTO `preload_tag`(`id`,`name`) VALUES (1,'a'),(2,'b');",
+		"INSERT INTO `preload_user_tag` VALUES (1,1),(1,2),(2,2);",
+	} {
+		require.NoError(t, RawQuery[any](db, s).Exec(ctx).Err())
+	}
+	uid := func(id int64) *int64 { return &id }
+
+	t.Run("has one, has many and many to many", func(t *testing.T) {
+		queries = nil
+		users, err := NewSelector[PreloadUser](db).
+			OrderBy(Asc("Id")).
+			Preload("Profile", "Orders", "Tags").
+			GetMulti(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []*PreloadUser{
+			{
+				Id: 1, Name: "Tom",
+				Profile: &PreloadProfile{Id: 1, UserId: 1, Bio: "cat"},
+				Orders: []*PreloadOrder{
+					{Id: 1, UserId: uid(1), Amount: 10},
+					{Id: 2, UserId: uid(1), Amount: 20},
+				},
+				Tags: []PreloadTag{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}},
+			},
+			{
+				Id: 2, Name: "Jerry",
+				Profile: &PreloadProfile{Id: 2, UserId: 2, Bio: "mouse"},
+				Orders:  []*PreloadOrder{{Id: 3, UserId: uid(2), Amount: 30}},
+				Tags:    []PreloadTag{{Id: 2, Name: "b"}},
+			},
+			{
+				Id: 3, Name: "Spike",
+				Orders: []*PreloadOrder{},
+				Tags:   []PreloadTag{},
+			},
+		}, users)
+		// 每个关联关系只有一次批量查询，多对多需要先查中间表
+		assert.Equal(t, []string{
+			"SELECT * FROM `preload_user` ORDER BY `id` ASC;",
+			"SELECT `id`,`user_id`,`bio` FROM `preload_profile` WHERE `user_id` IN (?,?,?);",
+			"SELECT `id`,`user_id`,`amount` FROM `preload_order` WHERE `user_id` IN (?,?,?);",
+			"SELECT `preload_user_id`,`preload_tag_id` FROM `preload_user_tag` WHERE `preload_user_id` IN (?,?,?);",
+			"SELECT `id`,`name` FROM `preload_tag` WHERE `id` IN (?,?);",
+		}, queries)
+	})
+
+	t.Run("belongs to", func(t *testing.T) {
+		queries = nil
+		orders, err := NewSelector[PreloadOrder](db).
+			OrderBy(Asc("Id")).
+			Preload("User").
+			GetMulti(ctx)
+		require.NoError(t, err)
+		tom := &PreloadUser{Id: 1, Name: "Tom"}
+		assert.Equal(t, []*PreloadOrder{
+			{Id: 1, UserId: uid(1), Amount: 10, User: tom},
+			{Id: 2, UserId: uid(1), Amount: 20, User: tom},
+			{Id: 3, UserId: uid(2), Amount: 30, User: &PreloadUser{Id: 2, Name: "Jerry"}},
+			// 外键是 NULL
+			{Id: 4, Amount: 40},
+		}, orders)
+		assert.Equal(t, []string{
+			"SELECT * FROM `preload_order` ORDER BY `id` ASC;",
+			"SELECT `id`,`name` FROM `preload_user` WHERE `id` IN (?,?);",
+		}, queries)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		user, err := NewSelector[PreloadUser](db).
+			Where(C("Id").EQ(2)).
+			Preload("Orders").
+			Get(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, &PreloadUser{
+			Id: 2, Name: "Jerry",
+			Orders: []*PreloadOrder{{Id: 3, UserId: uid(2), Amount: 30}},
+		}, user)
+	})
+
+	t.Run("chunk by max args", func(t *testing.T) {
+		dialect := db.dialect
+		defer func() {
+			db.dialect = dialect
+		}()
+		db.dialect = maxArgsDialect{Dialect: dialect, maxArgs: 2}
+		queries = nil
+		users, err := NewSelector[PreloadUser](db).
+			OrderBy(Asc("Id")).
+			Preload("Profile", "Tags").
+			GetMulti(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []*PreloadUser{
+			{
+				Id: 1, Name: "Tom",
+				Profile: &PreloadProfile{Id: 1, UserId: 1, Bio: "cat"},
+				Tags:    []PreloadTag{{Id: 1, Name: "a"}, {Id: 2, Name: "b"}},
+			},
+			{
+				Id: 2, Name: "Jerry",
+				Profile: &PreloadProfile{Id: 2, UserId: 2, Bio: "mouse"},
+				Tags:    []PreloadTag{{Id: 2, Name: "b"}},
+			},
+			{Id: 3, Name: "Spike", Tags: []PreloadTag{}},
+		}, users)
+		assert.Equal(t, []string{
+			"SELECT * FROM `preload_user` ORDER BY `id` ASC;",
+			"SELECT `id`,`user_id`,`bio` FROM `preload_profile` WHERE `user_id` IN (?,?);",
+			"SELECT `id`,`user_id`,`bio` FROM `preload_profile` WHERE `user_id` IN (?);",
+			"SELECT `preload_user_id`,`preload_tag_id` FROM `preload_user_tag` WHERE `preload_user_id` IN (?,?);",
+			"SELECT `preload_user_id`,`preload_tag_id` FROM `preload_user_tag` WHERE `preload_user_id` IN (?);",
+			"SELECT `id`,`name` FROM `preload_tag` WHERE `id` IN (?,?);",
+		}, queries)
+	})
+
+	t.Run("unknown relation", func(t *testing.T) {
+		_, err := NewSelector[PreloadUser](db).Preload("Name").GetMulti(ctx)
+		assert.Equal(t, errs.NewErrUnknownRelation("Name"), err)
+	})
+}
+
+// maxArgsDialect 限制一条语句的参数个数，用来测试分批查询
+type maxArgsDialect struct {
+	Dialect
+	maxArgs int
+}
+
+func (d maxArgsDialect) MaxArgs() int {
+	return d.maxArgs
+}
@@ -24,3 +24,39 @@ func (r Result) RowsAffected() (int64, error) {
 	}
 	return r.res.RowsAffected()
 }
+
+// returningResult 通过 RETURNING 拿到的自增主键
+type returningResult []int64
+
+// LastInsertId 和 SQLite 保持一致，返回最后一行的主键
+func (r returningResult) LastInsertId() (int64, error) {
+	if len(r) == 0 {
+		return 0, nil
+	}
+	return r[len(r)-1], nil
+}
+
+func (r returningResult) RowsAffected() (int64, error) {
+	return int64(len(r)), nil
+}
+
+// batchResult 分批执行的结果，每一批对应一个元素
+type batchResult []sql.Result
+
+// LastInsertId 返回最后一批的 LastInsertId
+func (r batchResult) LastInsertId() (int64, error) {
+	return r[len(r)-1].LastInsertId()
+}
+
+// RowsAffected 返回所有批次受影响行数的和
+func (r batchResult) RowsAffected() (int64, error) {
+	var sum int64
+	for _, res := range r {
+		affected, err := res.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		sum += affected
+	}
+	return sum, nil
+}
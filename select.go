@@ -4,118 +4,9 @@ import (
 	"context"
 	"github.com/valyala/bytebufferpool"
 	"orm/internal/errs"
+	"orm/model"
 )
 
-type Union struct {
-	Builder
-	left  QueryBuilder
-	typ   string
-	right QueryBuilder
-}
-
-func (u *Union) tableAlias() string {
-	return ""
-}
-
-func (u *Union) AsSubquery(alias string) Subquery {
-	return Subquery{
-		s:     u,
-		alias: alias,
-	}
-}
-
-func (u *Union) Build() (*Query, error) {
-	leftQuery, err := u.left.Build()
-	if err != nil {
-		return nil, err
-	}
-	rightQuery, err := u.right.Build()
-	if err != nil {
-		return nil, err
-	}
-	if leftQuery.SQL != "" {
-		u.writeString(leftQuery.SQL[:len(leftQuery.SQL)-1])
-	}
-	if leftQuery.Args != nil && len(leftQuery.Args) != 0 {
-		u.addArgs(leftQuery.Args...)
-	}
-
-	if u.typ != "" {
-		u.writeSpace()
-		u.writeString(u.typ)
-		u.writeSpace()
-	}
-
-	if rightQuery.SQL != "" {
-		u.writeString(rightQuery.SQL[:len(rightQuery.SQL)-1])
-	}
-	if rightQuery.Args != nil && len(rightQuery.Args) != 0 {
-		u.addArgs(rightQuery.Args...)
-	}
-
-	u.end()
-	return &Query{
-		SQL:  u.buffer.String(),
-		Args: u.args,
-	}, nil
-}
-
-func (s *Selector[T]) Union(q QueryBuilder) *Union {
-	return &Union{
-		left:  s,
-		typ:   "UNION",
-		right: q,
-		Builder: Builder{
-			core:     s.core,
-			buffer:   bytebufferpool.Get(),
-			aliasMap: make(map[string]int, 8),
-			quoter:   s.quoter,
-		},
-	}
-}
-
-func (s *Selector[T]) UnionAll(q QueryBuilder) *Union {
-	return &Union{
-		left:  s,
-		typ:   "UNION ALL",
-		right: q,
-		Builder: Builder{
-			core:     s.core,
-			buffer:   bytebufferpool.Get(),
-			aliasMap: make(map[string]int, 8),
-			quoter:   s.quoter,
-		},
-	}
-}
-
-func (u *Union) Union(q QueryBuilder) *Union {
-	return &Union{
-		left:  u,
-		typ:   "UNION",
-		right: q,
-		Builder: Builder{
-			core:     u.core,
-			buffer:   bytebufferpool.Get(),
-			aliasMap: make(map[string]int, 8),
-			quoter:   u.quoter,
-		},
-	}
-}
-
-func (u *Union) UnionAll(q QueryBuilder) *Union {
-	return &Union{
-		left:  u,
-		typ:   "UNION ALL",
-		right: q,
-		Builder: Builder{
-			core:     u.core,
-			buffer:   bytebufferpool.Get(),
-			aliasMap: make(map[string]int, 8),
-			quoter:   u.quoter,
-		},
-	}
-}
-
 // Selector 用于构造 SELECT 语句
 type Selector[T any] struct {
 	Builder
@@ -125,14 +16,24 @@ type Selector[T any] struct {
 	having *predicates
 	// select 查询的列
 	columns []Selectable
-	groupBy []Column
+	groupBy []Expression
 	orderBy []OrderBy
-	offset  int
-	limit   int
+	// distinct SELECT DISTINCT
+	distinct bool
+	// lock 行锁，为 nil 的时候不加锁
+	lock   *Lock
+	offset int
+	limit  int
+	// preloads 需要预加载的关联字段
+	preloads []string
+	// T 是组合结构体的时候，parts 是它的各个部分对应的表，
+	// scanModel 是用于接收结果的元数据
+	parts     []joinPart
+	scanModel *model.Model
 }
 
 // 定义个新的标记接口，限定传入的类型，这样我 们就可以做各种校验
-// 符合的结构体有: Column、Aggregate、RawExpr
+// 符合的结构体有: Column、Aggregate、RawExpr、FuncExpr、CaseExpr、WindowExpr
 
 type Selectable interface {
 	//selectable()
@@ -154,13 +55,55 @@ func NewSelector[T any](sess session) *Selector[T] {
 			core:     c,
 			buffer:   bytebufferpool.Get(),
 			aliasMap: make(map[string]int, 8),
-			quoter:   c.dialect.quoter(),
 		},
 	}
 }
 
-// GroupBy 设置 group by 子句
-func (s *Selector[T]) GroupBy(cols ...Column) *Selector[T] {
+// ForUpdate 加排他锁，一般在事务里面使用
+func (s *Selector[T]) ForUpdate() *Selector[T] {
+	s.lockOf().Share = false
+	return s
+}
+
+// ForShare 加共享锁
+func (s *Selector[T]) ForShare() *Selector[T] {
+	s.lockOf().Share = true
+	return s
+}
+
+// SkipLocked 跳过已经被其它事务锁住的行，适合用来实现任务队列。
+// 没有调用 ForUpdate 或者 ForShare 的时候，默认是 FOR UPDATE。
+// SkipLocked 和 NoWait 只能二选一，以最后一次调用为准
+func (s *Selector[T]) SkipLocked() *Selector[T] {
+	l := s.lockOf()
+	l.SkipLocked, l.NoWait = true, false
+	return s
+}
+
+// NoWait 行已经被锁住的时候立刻返回错误。
+// 没有调用 ForUpdate 或者 ForShare 的时候，默认是 FOR UPDATE。
+// SkipLocked 和 NoWait 只能二选一，以最后一次调用为准
+func (s *Selector[T]) NoWait() *Selector[T] {
+	l := s.lockOf()
+	l.NoWait, l.SkipLocked = true, false
+	return s
+}
+
+func (s *Selector[T]) lockOf() *Lock {
+	if s.lock == nil {
+		s.lock = &Lock{}
+	}
+	return s.lock
+}
+
+// Distinct 生成 SELECT DISTINCT，去掉重复的行
+func (s *Selector[T]) Distinct() *Selector[T] {
+	s.distinct = true
+	return s
+}
+
+// GroupBy 设置 group by 子句，一般是 Column，也可以是 Func 这种表达式
+func (s *Selector[T]) GroupBy(cols ...Expression) *Selector[T] {
 	s.groupBy = cols
 	return s
 }
@@ -195,6 +138,19 @@ func (s *Selector[T]) Where(ps ...Predicate) *Selector[T] {
 	return s
 }
 
+// With 定义一个 CTE，之后可以通过 CTE(name) 引用它
+func (s *Selector[T]) With(name string, q QueryBuilder) *Selector[T] {
+	s.with(name, q, false)
+	return s
+}
+
+// WithRecursive 定义一个递归的 CTE，q 一般是 UNION ALL，
+// 第二个查询里面通过 CTE(name) 引用它自己
+func (s *Selector[T]) WithRecursive(name string, q QueryBuilder) *Selector[T] {
+	s.with(name, q, true)
+	return s
+}
+
 // From 指定表对象，如果未指定，那么将会使用默认表名
 func (s *Selector[T]) From(tbl TableReference) *Selector[T] {
 	s.table = tbl
@@ -226,6 +182,10 @@ func (s *Selector[T]) buildAllColumns() {
 
 func (s *Selector[T]) buildColumns() error {
 	if len(s.columns) == 0 {
+		if len(s.parts) > 0 {
+			s.buildPartColumns()
+			return nil
+		}
 		//s.buildAllColumns()
 		s.writeByte('*')
 		return nil
@@ -236,6 +196,15 @@ func (s *Selector[T]) buildColumns() error {
 		}
 		switch val := col.(type) {
 		case Column:
+			if len(s.parts) > 0 {
+				label, err := s.partColumnLabel(val)
+				if err != nil {
+					return err
+				}
+				if label != "" {
+					val.alias = label
+				}
+			}
 			if err := s.buildColumn(val, true); err != nil {
 				return err
 			}
@@ -243,6 +212,27 @@ func (s *Selector[T]) buildColumns() error {
 			if err := s.buildAggregate(val, true); err != nil {
 				return err
 			}
+		case FuncExpr:
+			if err := s.buildFunc(val); err != nil {
+				return err
+			}
+			if err := s.buildAs(val.alias); err != nil {
+				return err
+			}
+		case CaseExpr:
+			if err := s.buildCase(val, false); err != nil {
+				return err
+			}
+			if err := s.buildAs(val.alias); err != nil {
+				return err
+			}
+		case WindowExpr:
+			if err := s.buildWindow(val); err != nil {
+				return err
+			}
+			if err := s.buildAs(val.alias); err != nil {
+				return err
+			}
 		case RawExpr:
 			s.writeString(val.raw)
 			if len(val.args) > 0 {
@@ -273,98 +263,26 @@ func (s *Selector[T]) buildGroupBy() error {
 		if i > 0 {
 			s.writeByte(',')
 		}
-		if err := s.buildColumn(col, false); err != nil {
+		if err := s.buildExpression(col, false, false); err != nil {
 			return err
 		}
 	}
 	return nil
 }
 
-func (s *Selector[T]) buildOrderBy() error {
-	for i, od := range s.orderBy {
-		if i > 0 {
-			s.writeByte(',')
-		}
-		fd, ok := s.model.FieldMap[od.col]
-		if !ok {
-			return errs.NewErrUnknownField(od.col)
-		}
-		s.writeByte('`')
-		s.writeString(fd.ColName)
-		s.writeByte('`')
-		s.writeString(" " + od.order)
-	}
-	return nil
-}
-
-func (s *Selector[T]) buildJoin(join Join) error {
-	s.writeLeftParenthesis()
-	if err := s.buildTable(join.left); err != nil {
-		return err
-	}
-	s.writeSpace()
-	s.writeString(join.typ)
-	s.writeSpace()
-	if err := s.buildTable(join.right); err != nil {
-		return err
-	}
-	if len(join.using) > 0 {
-		s.writeString(" USING ")
-		s.writeLeftParenthesis()
-		for i, col := range join.using {
-			if i > 0 {
-				s.writeComma()
-			}
-			err := s.buildColumn(Column{name: col}, false)
-			if err != nil {
-				return err
-			}
-		}
-		s.writeRightParenthesis()
-	}
-	if join.on != nil && len(join.on.ps) > 0 {
-		s.writeString(" ON ")
-		err := s.buildPredicates(join.on)
-		if err != nil {
-			return err
-		}
-	}
-	s.writeRightParenthesis()
-	return nil
-}
-
-func (s *Selector[T]) buildTable(table TableReference) error {
-	switch tab := table.(type) {
-	case nil:
-		s.quote(s.model.TableName)
-	case Table:
-		meta, err := s.r.Get(tab.entity)
-		if err != nil {
-			return err
-		}
-		s.quote(meta.TableName)
-		return s.buildAs(tab.alias)
-	case Join:
-		return s.buildJoin(tab)
-	case Subquery:
-		return s.buildSubquery(tab, true)
-	default:
-		return errs.NewErrUnsupportedExpressionType(tab)
-	}
-	return nil
-}
-
 func (s *Selector[T]) Build() (*Query, error) {
-	defer bytebufferpool.Put(s.buffer)
+	defer s.release()
 	var err error
-	if s.model == nil {
-		t := s.TableOf()
-		s.model, err = s.r.Get(t)
-		if err != nil {
-			return nil, err
-		}
+	if err = s.initModel(); err != nil {
+		return nil, err
+	}
+	if err = s.buildWith(); err != nil {
+		return nil, err
 	}
 	s.writeString("SELECT ")
+	if s.distinct {
+		s.writeString("DISTINCT ")
+	}
 	if err = s.buildColumns(); err != nil {
 		return nil, err
 	}
@@ -384,7 +302,7 @@ func (s *Selector[T]) Build() (*Query, error) {
 	if len(s.groupBy) > 0 {
 		s.writeString(" GROUP BY ")
 		// GROUP BY 理论上可以用别名，但这里不允许，用户完全可以通过简单的修改代码避免使用别名的这种用法。
-		// 也不支持复杂的表达式，因为复杂的表达式和 group by 混用是非常罕见的
+		// 除了列以外，也可以使用 Func 这种表达式
 		if err = s.buildGroupBy(); err != nil {
 			return nil, err
 		}
@@ -398,17 +316,22 @@ func (s *Selector[T]) Build() (*Query, error) {
 	}
 	if len(s.orderBy) > 0 {
 		s.writeString(" ORDER BY ")
-		if err = s.buildOrderBy(); err != nil {
+		// ORDER BY 可以使用别名
+		if err = s.buildOrderBy(s.orderBy, true); err != nil {
 			return nil, err
 		}
 	}
-	if s.limit > 0 {
-		s.writeString(" LIMIT ?")
-		s.addArgs(s.limit)
+	if s.limit > 0 || s.offset > 0 {
+		// 不同数据库的分页语法差异很大，所以交给方言
+		err = s.dialect.BuildLimitOffset(&s.Builder, s.limit, s.offset, len(s.orderBy) > 0)
+		if err != nil {
+			return nil, err
+		}
 	}
-	if s.offset > 0 {
-		s.writeString(" OFFSET ?")
-		s.addArgs(s.offset)
+	if s.lock != nil {
+		if err = s.dialect.BuildLock(&s.Builder, *s.lock); err != nil {
+			return nil, err
+		}
 	}
 
 	s.end()
@@ -419,43 +342,75 @@ func (s *Selector[T]) Build() (*Query, error) {
 }
 
 func (s *Selector[T]) Get(ctx context.Context) (*T, error) {
-	if s.model == nil {
-		t := s.TableOf()
-		m, err := s.r.Get(t)
-		if err != nil {
-			return nil, err
-		}
-		s.model = m
+	if err := s.initModel(); err != nil {
+		return nil, err
 	}
 	res := get[T](ctx, s.core, s.sess, &QueryContext{
 		Builder: s,
 		Type:    "SELECT",
-		Meta:    s.model,
+		Meta:    s.meta(),
 	})
 	if res.Err != nil {
 		return nil, res.Err
 	}
-	return res.Result.(*T), nil
+	t := res.Result.(*T)
+	if err := s.preload(ctx, []*T{t}); err != nil {
+		return nil, err
+	}
+	return t, nil
 }
 
 func (s *Selector[T]) GetMulti(ctx context.Context) ([]*T, error) {
-	if s.model == nil {
-		t := s.TableOf()
-		m, err := s.r.Get(t)
-		if err != nil {
-			return nil, err
-		}
-		s.model = m
+	if err := s.initModel(); err != nil {
+		return nil, err
 	}
 	res := getMulti[T](ctx, s.core, s.sess, &QueryContext{
 		Builder: s,
 		Type:    "SELECT",
-		Meta:    s.model,
+		Meta:    s.meta(),
 	})
 	if res.Err != nil {
 		return nil, res.Err
 	}
-	return res.Result.([]*T), nil
+	ts := res.Result.([]*T)
+	if err := s.preload(ctx, ts); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// initModel 初始化元数据。
+// 一般情况下元数据来自 FROM 里面的表，
+// 但是如果 T 是组合结构体，那么使用 T 的元数据，并且为它的每一部分找到对应的表
+func (s *Selector[T]) initModel() error {
+	if s.model != nil {
+		return s.resolveParts()
+	}
+	t := s.TableOf()
+	if _, ok := t.(*T); !ok {
+		if m, err := s.r.Get(new(T)); err == nil && len(m.Parts) > 0 {
+			t = new(T)
+		}
+	}
+	m, err := s.r.Get(t)
+	if err != nil {
+		return err
+	}
+	s.model = m
+	return s.resolveParts()
+}
+
+// paginated 是否有 ORDER BY、LIMIT 或者 OFFSET
+func (s *Selector[T]) paginated() bool {
+	return len(s.orderBy) > 0 || s.limit > 0 || s.offset > 0
+}
+
+// meta 返回用于接收结果的元数据
+func (s *Selector[T]) meta() *model.Model {
+	if s.scanModel != nil {
+		return s.scanModel
+	}
+	return s.model
 }
 
 func (s *Selector[T]) TableOf() any {
@@ -110,20 +110,20 @@ func TestSelector_Union(t *testing.T) {
 				Args: []any{3},
 			},
 		},
-		//{
-		//	name: "subquery union",
-		//	q: func() QueryBuilder {
-		//		sub := NewSelector[OrderDetail](db).Select(C("OrderId")).AsSubquery("sub")
-		//		selector1 := NewSelector[Order](db).Where(C("Id").In(sub))
-		//		selector2 := NewSelector[Pay](db).Select().Where(C("Price").EQ(3))
-		//		sub = selector1.Union(selector2).AsSubquery("sub2")
-		//		return NewSelector[Order](db).From(sub)
-		//	}(),
-		//	wantQuery: &Query{
-		//		SQL:  "SELECT * FROM (SELECT * FROM `order` WHERE `id` IN (SELECT `order_id` FROM `order_detail`) UNION SELECT * FROM `pay` WHERE `price` = ?) AS `sub2`;",
-		//		Args: []any{3},
-		//	},
-		//},
+		{
+			name: "subquery union",
+			q: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId")).AsSubquery("sub")
+				selector1 := NewSelector[Order](db).Where(C("Id").In(sub))
+				selector2 := NewSelector[Pay](db).Select().Where(C("Price").EQ(3))
+				sub = selector1.Union(selector2).AsSubquery("sub2")
+				return NewSelector[Order](db).From(sub)
+			}(),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM (SELECT * FROM `order` WHERE `id` IN (SELECT `order_id` FROM `order_detail`) UNION SELECT * FROM `pay` WHERE `price` = ?) AS `sub2`;",
+				Args: []any{3},
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -166,7 +166,7 @@ func TestSelector_SubqueryAndJoin(t *testing.T) {
 					From(t1.Join(sub).On(t1.C("Id").EQ(sub.C("OrderId"))))
 			}(),
 			wantQuery: &Query{
-				SQL: "SELECT `sub`.`item_id` FROM (`order` JOIN (SELECT * FROM `order_detail`) AS `sub` ON `id` = `sub`.`order_id`);",
+				SQL: "SELECT `sub`.`item_id` FROM (`order` JOIN (SELECT * FROM `order_detail`) AS `sub` ON `order`.`id` = `sub`.`order_id`);",
 			},
 		},
 		{
@@ -178,7 +178,7 @@ func TestSelector_SubqueryAndJoin(t *testing.T) {
 					From(t1.LeftJoin(sub).On(t1.C("Id").EQ(sub.C("OrderId"))))
 			}(),
 			wantQuery: &Query{
-				SQL: "SELECT `sub`.`item_id` FROM (`order` LEFT JOIN (SELECT * FROM `order_detail`) AS `sub` ON `id` = `sub`.`order_id`);",
+				SQL: "SELECT `sub`.`item_id` FROM (`order` LEFT JOIN (SELECT * FROM `order_detail`) AS `sub` ON `order`.`id` = `sub`.`order_id`);",
 			},
 		},
 		{
@@ -375,6 +375,31 @@ func TestSelector_Subquery(t *testing.T) {
 				SQL: "SELECT * FROM `order` WHERE (`id` > SOME (SELECT `order_id` FROM `order_detail`)) AND (`id` < ANY (SELECT `order_id` FROM `order_detail`));",
 			},
 		},
+		{
+			// 同一个子查询被构造两次，参数也要出现两次
+			name: "reused subquery with args",
+			q: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId")).Where(C("ItemId").GT(10)).AsSubquery("sub")
+				return NewSelector[Order](db).Where(C("Id").GT(Some(sub)).And(C("Id").LT(Any(sub))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order` WHERE (`id` > SOME (SELECT `order_id` FROM `order_detail` WHERE `item_id` > ?)) " +
+					"AND (`id` < ANY (SELECT `order_id` FROM `order_detail` WHERE `item_id` > ?));",
+				Args: []any{10, 10},
+			},
+		},
+		{
+			// 别名在第二次构造的时候不会被当成重复的
+			name: "reused subquery with alias",
+			q: func() QueryBuilder {
+				sub := NewSelector[OrderDetail](db).Select(C("OrderId").As("oid")).AsSubquery("sub")
+				return NewSelector[Order](db).Where(C("Id").In(sub).Or(C("Id").GT(All(sub))))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `order` WHERE (`id` IN (SELECT `order_id` AS `oid` FROM `order_detail`)) " +
+					"OR (`id` > ALL (SELECT `order_id` AS `oid` FROM `order_detail`));",
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -433,7 +458,7 @@ func TestSelector_Join(t *testing.T) {
 					From(t1.Join(t2).On(t1.C("Id").EQ(t2.C("OrderId"))))
 			}(),
 			wantQuery: &Query{
-				SQL: "SELECT * FROM (`order` AS `t1` JOIN `order_detail` ON `t1`.`id` = `order_id`);",
+				SQL: "SELECT * FROM (`order` AS `t1` JOIN `order_detail` ON `t1`.`id` = `order_detail`.`order_id`);",
 			},
 		},
 		{
@@ -613,6 +638,24 @@ func TestSelector_Having(t *testing.T) {
 				Args: []any{18},
 			},
 		},
+		{
+			name: "aggregate comparison",
+			q: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(Count("Id").GTE(2), Max("Id").NEQ(10), Sum("Age").Between(10, 100)),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `age` " +
+					"HAVING ((COUNT(`id`) >= ?) AND (MAX(`id`) != ?)) AND (SUM(`age`) BETWEEN ? AND ?);",
+				Args: []any{2, 10, 10, 100},
+			},
+		},
+		{
+			name: "aggregate is null",
+			q: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(Max("LastName").IsNotNull()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` GROUP BY `age` HAVING MAX(`last_name`) IS NOT NULL;",
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -729,6 +772,42 @@ func TestSelector_Select(t *testing.T) {
 				SQL: "SELECT `id` AS `my_id`,AVG(`age`) AS `avg_age` FROM `test_model`;",
 			},
 		},
+		{
+			name: "distinct",
+			q:    NewSelector[TestModel](db).Distinct().Select(C("FirstName"), C("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT DISTINCT `first_name`,`age` FROM `test_model`;",
+			},
+		},
+		{
+			name: "distinct all",
+			q:    NewSelector[TestModel](db).Distinct(),
+			wantQuery: &Query{
+				SQL: "SELECT DISTINCT * FROM `test_model`;",
+			},
+		},
+		{
+			name: "distinct aggregate",
+			q: NewSelector[TestModel](db).
+				Select(CountDistinct("FirstName").As("cnt"), SumDistinct("Age"), AvgDistinct("Age")),
+			wantQuery: &Query{
+				SQL: "SELECT COUNT(DISTINCT `first_name`) AS `cnt`,SUM(DISTINCT `age`),AVG(DISTINCT `age`) FROM `test_model`;",
+			},
+		},
+		{
+			name: "distinct aggregate in having",
+			q: NewSelector[TestModel](db).GroupBy(C("Age")).
+				Having(CountDistinct("FirstName").GT(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` GROUP BY `age` HAVING COUNT(DISTINCT `first_name`) > ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name:    "invalid distinct column",
+			q:       NewSelector[TestModel](db).Select(CountDistinct("Invalid")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
 		// WHERE 忽略别名
 		{
 			name: "where ignore alias",
@@ -823,6 +902,140 @@ func TestSelector_Build(t *testing.T) {
 			q:       NewSelector[TestModel](db).Where(Not(C("Invalid").GT(18))),
 			wantErr: errs.NewErrUnknownField("Invalid"),
 		},
+		{
+			name: "comparison",
+			q: NewSelector[TestModel](db).
+				Where(C("Id").NEQ(1), C("Age").GTE(18), C("Age").LTE(35)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE ((`id` != ?) AND (`age` >= ?)) AND (`age` <= ?);",
+				Args: []any{1, 18, 35},
+			},
+		},
+		{
+			name: "like",
+			q: NewSelector[TestModel](db).
+				Where(C("FirstName").Like("Tom%").Or(C("FirstName").NotLike("%Jerry"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`first_name` LIKE ?) OR (`first_name` NOT LIKE ?);",
+				Args: []any{"Tom%", "%Jerry"},
+			},
+		},
+		{
+			name: "between",
+			q:    NewSelector[TestModel](db).Where(C("Age").Between(18, 35)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` BETWEEN ? AND ?;",
+				Args: []any{18, 35},
+			},
+		},
+		{
+			name: "between and",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").Between(18, 35).And(C("Id").GT(10))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (`age` BETWEEN ? AND ?) AND (`id` > ?);",
+				Args: []any{18, 35, 10},
+			},
+		},
+		{
+			name: "not in",
+			q:    NewSelector[TestModel](db).Where(C("Id").NotIn(Raw("(1,2)"))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE `id` NOT IN (1,2);",
+			},
+		},
+		{
+			name: "in values",
+			q:    NewSelector[TestModel](db).Where(C("Id").In(1, 2, 3)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` IN (?,?,?);",
+				Args: []any{1, 2, 3},
+			},
+		},
+		{
+			name: "in slice",
+			q:    NewSelector[TestModel](db).Where(C("FirstName").In([]string{"Tom", "Jerry"})),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` IN (?,?);",
+				Args: []any{"Tom", "Jerry"},
+			},
+		},
+		{
+			name: "in single value",
+			q:    NewSelector[TestModel](db).Where(C("Id").In(1)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` IN (?);",
+				Args: []any{1},
+			},
+		},
+		{
+			// []byte 是一个值，不会被展开
+			name: "in bytes",
+			q:    NewSelector[TestModel](db).Where(C("FirstName").In([]byte("Tom"))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `first_name` IN (?);",
+				Args: []any{[]byte("Tom")},
+			},
+		},
+		{
+			// 空列表永远为假
+			name: "in empty slice",
+			q: NewSelector[TestModel](db).
+				Where(C("Id").In([]int{}), C("Age").GT(18)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE (1 = 0) AND (`age` > ?);",
+				Args: []any{18},
+			},
+		},
+		{
+			// 空列表永远为真
+			name: "not in empty",
+			q:    NewSelector[TestModel](db).Where(C("Id").NotIn()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE 1 = 1;",
+			},
+		},
+		{
+			name: "not in slice",
+			q:    NewSelector[TestModel](db).Where(C("Id").NotIn([]int{1, 2})),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` NOT IN (?,?);",
+				Args: []any{1, 2},
+			},
+		},
+		{
+			name:    "invalid column in empty in",
+			q:       NewSelector[TestModel](db).Where(C("Invalid").In([]int{})),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "is null",
+			q: NewSelector[TestModel](db).
+				Where(C("LastName").IsNull().Or(C("FirstName").IsNotNull())),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` WHERE (`last_name` IS NULL) OR (`first_name` IS NOT NULL);",
+			},
+		},
+		{
+			name: "math expression",
+			q: NewSelector[TestModel](db).
+				Where(C("Age").Add(1).GTE(18), C("Age").Multi(2).Between(20, 40)),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE ((`age` + ?) >= ?) AND ((`age` * ?) BETWEEN ? AND ?);",
+				Args: []any{1, 18, 2, 20, 40},
+			},
+		},
+		{
+			// 新的操作符同样会校验字段名
+			name:    "invalid column in between",
+			q:       NewSelector[TestModel](db).Where(C("Invalid").Between(1, 2)),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name:    "invalid column in is null",
+			q:       NewSelector[TestModel](db).Where(C("Invalid").IsNull()),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
 		{
 			// 使用 RawExpr
 			name: "raw expression",
@@ -1659,3 +1872,215 @@ func BenchmarkQuerier_Get(b *testing.B) {
 		}
 	})
 }
+
+func TestSelector_Lock(t *testing.T) {
+	testCases := []struct {
+		name      string
+		dialect   Dialect
+		q         func(db *DB) QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name:    "mysql for update",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Where(C("Id").EQ(1)).ForUpdate()
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `id` = ? FOR UPDATE;",
+				Args: []any{1},
+			},
+		},
+		{
+			name:    "mysql for share nowait",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).ForShare().NoWait()
+			},
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` FOR SHARE NOWAIT;",
+			},
+		},
+		{
+			// 领取下一个任务
+			name:    "mysql skip locked",
+			dialect: MySQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Where(C("Age").EQ(0)).
+					OrderBy(Asc("Id")).Limit(1).SkipLocked()
+			},
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` WHERE `age` = ? ORDER BY `id` ASC LIMIT ? FOR UPDATE SKIP LOCKED;",
+				Args: []any{0, 1},
+			},
+		},
+		{
+			// SkipLocked 和 NoWait 以最后一次调用为准
+			name:    "postgres nowait then skip locked",
+			dialect: Postgres,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).Limit(10).ForUpdate().NoWait().SkipLocked()
+			},
+			wantQuery: &Query{
+				SQL:  `SELECT * FROM "test_model" LIMIT $1 FOR UPDATE SKIP LOCKED;`,
+				Args: []any{10},
+			},
+		},
+		{
+			name:    "postgres skip locked then nowait",
+			dialect: Postgres,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).ForShare().SkipLocked().NoWait()
+			},
+			wantQuery: &Query{
+				SQL: `SELECT * FROM "test_model" FOR SHARE NOWAIT;`,
+			},
+		},
+		{
+			name:    "sqlite",
+			dialect: SQLite3,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).ForUpdate()
+			},
+			wantErr: errs.ErrUnsupportedLock,
+		},
+		{
+			name:    "mssql",
+			dialect: MSSQL,
+			q: func(db *DB) QueryBuilder {
+				return NewSelector[TestModel](db).ForShare()
+			},
+			wantErr: errs.ErrUnsupportedLock,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := memoryDB(t, DBWithDialect(tc.dialect))
+			query, err := tc.q(db).Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_OrderBy(t *testing.T) {
+	db := memoryDB(t)
+	type Order struct {
+		Id     int
+		UserId int
+		Price  int
+	}
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "column",
+			q:    NewSelector[TestModel](db).OrderBy(Asc("Age"), Desc(C("Id"))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `age` ASC,`id` DESC;",
+			},
+		},
+		{
+			name: "joined table",
+			q: func() QueryBuilder {
+				t1 := TableOf(&TestModel{}).As("t1")
+				t2 := TableOf(&Order{}).As("t2")
+				return NewSelector[TestModel](db).
+					From(t1.Join(t2).On(t1.C("Id").EQ(t2.C("UserId")))).
+					OrderBy(Desc(t2.C("Price")), Asc(t1.C("Id")))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM (`test_model` AS `t1` JOIN `order` AS `t2` ON `t1`.`id` = `t2`.`user_id`) " +
+					"ORDER BY `t2`.`price` DESC,`t1`.`id` ASC;",
+			},
+		},
+		{
+			name: "aggregate and alias",
+			q: NewSelector[TestModel](db).Select(C("Age"), Count("Id").As("cnt")).
+				GroupBy(C("Age")).OrderBy(Desc("cnt"), Asc(Max("Id").As("max_id"))),
+			wantQuery: &Query{
+				SQL: "SELECT `age`,COUNT(`id`) AS `cnt` FROM `test_model` GROUP BY `age` " +
+					"ORDER BY `cnt` DESC,MAX(`id`) ASC;",
+			},
+		},
+		{
+			name: "math expression",
+			q:    NewSelector[TestModel](db).OrderBy(Desc(C("Age").Multi(2))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `test_model` ORDER BY (`age` * ?) DESC;",
+				Args: []any{2},
+			},
+		},
+		{
+			name: "nulls",
+			q: NewSelector[TestModel](db).
+				OrderBy(Asc("LastName").NullsLast(), Desc("Age").NullsFirst()),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY `last_name` ASC NULLS LAST,`age` DESC NULLS FIRST;",
+			},
+		},
+		{
+			name:    "unknown alias",
+			q:       NewSelector[TestModel](db).OrderBy(Asc("cnt")),
+			wantErr: errs.NewErrUnknownField("cnt"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			query, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
+
+func TestSelector_OrderByNulls(t *testing.T) {
+	testCases := []struct {
+		name      string
+		dialect   Dialect
+		wantQuery *Query
+	}{
+		{
+			name:    "mysql",
+			dialect: MySQL,
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `test_model` ORDER BY CASE WHEN `last_name` IS NULL THEN 1 ELSE 0 END,`last_name` ASC," +
+					"CASE WHEN LOWER(`first_name`) IS NULL THEN 0 ELSE 1 END,LOWER(`first_name`) DESC;",
+			},
+		},
+		{
+			name:    "postgres",
+			dialect: Postgres,
+			wantQuery: &Query{
+				SQL: `SELECT * FROM "test_model" ORDER BY "last_name" ASC NULLS LAST,LOWER("first_name") DESC NULLS FIRST;`,
+			},
+		},
+		{
+			name:    "mssql",
+			dialect: MSSQL,
+			wantQuery: &Query{
+				SQL: "SELECT * FROM [test_model] ORDER BY CASE WHEN [last_name] IS NULL THEN 1 ELSE 0 END,[last_name] ASC," +
+					"CASE WHEN LOWER([first_name]) IS NULL THEN 0 ELSE 1 END,LOWER([first_name]) DESC;",
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := memoryDB(t, DBWithDialect(tc.dialect))
+			query, err := NewSelector[TestModel](db).
+				OrderBy(Asc("LastName").NullsLast(), Desc(Lower(C("FirstName"))).NullsFirst()).Build()
+			require.NoError(t, err)
+			assert.Equal(t, tc.wantQuery, query)
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package orm
+
+import (
+	"context"
+	"github.com/valyala/bytebufferpool"
+	"orm/internal/errs"
+	"strings"
+)
+
+const (
+	setUnion     = "UNION"
+	setUnionAll  = "UNION ALL"
+	setIntersect = "INTERSECT"
+	setExcept    = "EXCEPT"
+)
+
+// SetQuery 代表 UNION、INTERSECT 和 EXCEPT 这些集合操作，
+// 可以在结果上继续使用 OrderBy、Limit 和 Offset，
+// 并且可以通过 Get 和 GetMulti 直接执行，结果按照 T 来接收。
+//
+// 注意多个集合操作连在一起的时候，在 PostgreSQL 和 MySQL 里面 INTERSECT 的优先级比 UNION 高，
+// 而 SQLite 是从左往右执行的。需要的话可以使用 Parenthesize，但是 SQLite 不支持括号，
+// 在 SQLite 上使用 Parenthesize 会返回 errs.ErrUnsupportedParenthesize
+type SetQuery[T any] struct {
+	Builder
+	sess    session
+	left    QueryBuilder
+	typ     string
+	right   QueryBuilder
+	paren   bool
+	orderBy []OrderBy
+	offset  int
+	limit   int
+}
+
+func newSetQuery[T any](sess session, left QueryBuilder, typ string, right QueryBuilder) *SetQuery[T] {
+	return &SetQuery[T]{
+		sess:  sess,
+		left:  left,
+		typ:   typ,
+		right: right,
+		Builder: Builder{
+			core:     sess.getCore(),
+			buffer:   bytebufferpool.Get(),
+			aliasMap: make(map[string]int, 8),
+		},
+	}
+}
+
+func (s *Selector[T]) Union(q QueryBuilder) *SetQuery[T] {
+	return newSetQuery[T](s.sess, s, setUnion, q)
+}
+
+func (s *Selector[T]) UnionAll(q QueryBuilder) *SetQuery[T] {
+	return newSetQuery[T](s.sess, s, setUnionAll, q)
+}
+
+// Intersect 两个查询都有的行，注意 MySQL 从 8.0.31 开始才支持
+func (s *Selector[T]) Intersect(q QueryBuilder) *SetQuery[T] {
+	return newSetQuery[T](s.sess, s, setIntersect, q)
+}
+
+// Except 在当前查询但是不在 q 里面的行，注意 MySQL 从 8.0.31 开始才支持
+func (s *Selector[T]) Except(q QueryBuilder) *SetQuery[T] {
+	return newSetQuery[T](s.sess, s, setExcept, q)
+}
+
+func (u *SetQuery[T]) Union(q QueryBuilder) *SetQuery[T] {
+	return newSetQuery[T](u.sess, u, setUnion, q)
+}
+
+func (u *SetQuery[T]) UnionAll(q QueryBuilder) *SetQuery[T] {
+	return newSetQuery[T](u.sess, u, setUnionAll, q)
+}
+
+func (u *SetQuery[T]) Intersect(q QueryBuilder) *SetQuery[T] {
+	return newSetQuery[T](u.sess, u, setIntersect, q)
+}
+
+func (u *SetQuery[T]) Except(q QueryBuilder) *SetQuery[T] {
+	return newSetQuery[T](u.sess, u, setExcept, q)
+}
+
+// Parenthesize 每一个子查询都用括号括起来。
+// 有 ORDER BY、LIMIT 或者 OFFSET 的子查询总是会加上括号，
+// 不支持括号的方言会将其包装成 SELECT * FROM (...)
+func (u *SetQuery[T]) Parenthesize() *SetQuery[T] {
+	u.paren = true
+	return u
+}
+
+// OrderBy 对整个结果排序，字段名按照 T 来解析
+func (u *SetQuery[T]) OrderBy(orderBys ...OrderBy) *SetQuery[T] {
+	u.orderBy = orderBys
+	return u
+}
+
+func (u *SetQuery[T]) Limit(limit int) *SetQuery[T] {
+	u.limit = limit
+	return u
+}
+
+func (u *SetQuery[T]) Offset(offset int) *SetQuery[T] {
+	u.offset = offset
+	return u
+}
+
+func (u *SetQuery[T]) tableAlias() string {
+	return ""
+}
+
+func (u *SetQuery[T]) AsSubquery(alias string) Subquery {
+	return Subquery{
+		s:     u,
+		alias: alias,
+	}
+}
+
+// leftOperand 返回第一个查询，结果的列由它决定
+func (u *SetQuery[T]) leftOperand() QueryBuilder {
+	return u.left
+}
+
+// paginated 是否有 ORDER BY、LIMIT 或者 OFFSET，
+// 这种查询作为集合操作的一部分的时候必须加上括号
+func (u *SetQuery[T]) paginated() bool {
+	return len(u.orderBy) > 0 || u.limit > 0 || u.offset > 0
+}
+
+func (u *SetQuery[T]) Build() (*Query, error) {
+	defer u.release()
+	if err := u.initModel(); err != nil {
+		return nil, err
+	}
+	if u.paren && !u.dialect.SupportParenthesizedOperand() {
+		return nil, errs.ErrUnsupportedParenthesize
+	}
+	if err := u.buildOperand(u.left); err != nil {
+		return nil, err
+	}
+	u.writeSpace()
+	u.writeString(u.typ)
+	u.writeSpace()
+	if err := u.buildOperand(u.right); err != nil {
+		return nil, err
+	}
+	if len(u.orderBy) > 0 {
+		u.writeString(" ORDER BY ")
+		if err := u.buildOrderBy(u.orderBy, true); err != nil {
+			return nil, err
+		}
+	}
+	if u.limit > 0 || u.offset > 0 {
+		err := u.dialect.BuildLimitOffset(&u.Builder, u.limit, u.offset, len(u.orderBy) > 0)
+		if err != nil {
+			return nil, err
+		}
+	}
+	u.end()
+	return &Query{
+		SQL:  u.buffer.String(),
+		Args: u.args,
+	}, nil
+}
+
+func (u *SetQuery[T]) buildOperand(q QueryBuilder) error {
+	if setter, ok := q.(argsOffsetSetter); ok {
+		setter.setArgsOffset(u.argsOffset + len(u.args))
+	}
+	u.passCTEs(q)
+	query, err := q.Build()
+	if err != nil {
+		return err
+	}
+	p, ok := q.(interface{ paginated() bool })
+	paren := u.paren || (ok && p.paginated())
+	if paren && !u.dialect.SupportParenthesizedOperand() {
+		// 作为子查询的时候 ORDER BY 和 LIMIT 依旧有效
+		u.writeString("SELECT * FROM ")
+	}
+	if paren {
+		u.writeLeftParenthesis()
+	}
+	u.writeString(trimEnd(query.SQL))
+	if paren {
+		u.writeRightParenthesis()
+	}
+	if len(query.Args) > 0 {
+		u.addArgs(query.Args...)
+	}
+	return nil
+}
+
+func (u *SetQuery[T]) Get(ctx context.Context) (*T, error) {
+	if err := u.initModel(); err != nil {
+		return nil, err
+	}
+	res := get[T](ctx, u.core, u.sess, &QueryContext{
+		Builder: u,
+		Type:    "SELECT",
+		Meta:    u.model,
+	})
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return res.Result.(*T), nil
+}
+
+func (u *SetQuery[T]) GetMulti(ctx context.Context) ([]*T, error) {
+	if err := u.initModel(); err != nil {
+		return nil, err
+	}
+	res := getMulti[T](ctx, u.core, u.sess, &QueryContext{
+		Builder: u,
+		Type:    "SELECT",
+		Meta:    u.model,
+	})
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	return res.Result.([]*T), nil
+}
+
+func (u *SetQuery[T]) initModel() error {
+	if u.model != nil {
+		return nil
+	}
+	m, err := u.r.Get(new(T))
+	if err != nil {
+		return err
+	}
+	u.model = m
+	return nil
+}
+
+// trimEnd 去掉末尾的分号，用于把一个完整的查询嵌入到另外一个查询里面
+func trimEnd(sql string) string {
+	return strings.TrimSuffix(sql, ";")
+}
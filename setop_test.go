@@ -0,0 +1,205 @@
+package orm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"orm/internal/errs"
+	"testing"
+)
+
+type SetOrder struct {
+	Id     int64 `orm:"pk"`
+	UserId int64
+	Price  int
+}
+
+func TestSetQuery_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "intersect",
+			q: NewSelector[SetOrder](db).Where(C("Price").GT(10)).
+				Intersect(NewSelector[SetOrder](db).Where(C("UserId").EQ(1))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM `set_order` WHERE `price` > ? INTERSECT SELECT * FROM `set_order` WHERE `user_id` = ?;",
+				Args: []any{10, 1},
+			},
+		},
+		{
+			name: "except",
+			q: NewSelector[SetOrder](db).Select(C("UserId")).
+				Except(NewSelector[SetOrder](db).Select(C("UserId")).Where(C("Price").LT(10))),
+			wantQuery: &Query{
+				SQL:  "SELECT `user_id` FROM `set_order` EXCEPT SELECT `user_id` FROM `set_order` WHERE `price` < ?;",
+				Args: []any{10},
+			},
+		},
+		{
+			name: "chain",
+			q: NewSelector[SetOrder](db).Where(C("Id").EQ(1)).
+				UnionAll(NewSelector[SetOrder](db).Where(C("Id").EQ(2))).
+				Except(NewSelector[SetOrder](db).Where(C("Id").EQ(3))),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `set_order` WHERE `id` = ? UNION ALL SELECT * FROM `set_order` WHERE `id` = ? " +
+					"EXCEPT SELECT * FROM `set_order` WHERE `id` = ?;",
+				Args: []any{1, 2, 3},
+			},
+		},
+		{
+			name: "order by limit offset",
+			q: NewSelector[SetOrder](db).Where(C("UserId").EQ(1)).
+				Union(NewSelector[SetOrder](db).Where(C("UserId").EQ(2))).
+				OrderBy(Desc("Price"), Asc("Id")).Limit(10).Offset(20),
+			wantQuery: &Query{
+				SQL: "SELECT * FROM `set_order` WHERE `user_id` = ? UNION SELECT * FROM `set_order` WHERE `user_id` = ? " +
+					"ORDER BY `price` DESC,`id` ASC LIMIT ? OFFSET ?;",
+				Args: []any{1, 2, 10, 20},
+			},
+		},
+		{
+			// SQLite 不支持括号，有 LIMIT 的子查询会被包装成 SELECT * FROM (...)
+			name: "paginated operand",
+			q: NewSelector[SetOrder](db).OrderBy(Desc("Price")).Limit(1).
+				UnionAll(NewSelector[SetOrder](db).Where(C("Id").EQ(1))),
+			wantQuery: &Query{
+				SQL:  "SELECT * FROM (SELECT * FROM `set_order` ORDER BY `price` DESC LIMIT ?) UNION ALL SELECT * FROM `set_order` WHERE `id` = ?;",
+				Args: []any{1, 1},
+			},
+		},
+		{
+			name: "parenthesize",
+			q: NewSelector[SetOrder](db).Where(C("Id").EQ(1)).
+				Union(NewSelector[SetOrder](db).Where(C("Id").EQ(2))).
+				Parenthesize(),
+			wantErr: errs.ErrUnsupportedParenthesize,
+		},
+		{
+			name: "invalid order by",
+			q: NewSelector[SetOrder](db).Union(NewSelector[SetOrder](db)).
+				OrderBy(Asc("Invalid")),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSetQuery_MySQL(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(MySQL))
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			// 有 LIMIT 的子查询会自动加上括号
+			name: "paginated operand",
+			q: NewSelector[SetOrder](db).OrderBy(Desc("Price")).Limit(1).
+				UnionAll(NewSelector[SetOrder](db).Where(C("Id").EQ(1))),
+			wantQuery: &Query{
+				SQL:  "(SELECT * FROM `set_order` ORDER BY `price` DESC LIMIT ?) UNION ALL SELECT * FROM `set_order` WHERE `id` = ?;",
+				Args: []any{1, 1},
+			},
+		},
+		{
+			name: "parenthesize",
+			q: NewSelector[SetOrder](db).Where(C("Id").EQ(1)).
+				Union(NewSelector[SetOrder](db).Where(C("Id").EQ(2))).
+				Parenthesize().
+				Intersect(NewSelector[SetOrder](db).Where(C("Id").EQ(3))),
+			wantQuery: &Query{
+				SQL: "(SELECT * FROM `set_order` WHERE `id` = ?) UNION (SELECT * FROM `set_order` WHERE `id` = ?) " +
+					"INTERSECT SELECT * FROM `set_order` WHERE `id` = ?;",
+				Args: []any{1, 2, 3},
+			},
+		},
+		{
+			name: "parenthesize nested",
+			q: NewSelector[SetOrder](db).Where(C("Id").EQ(1)).
+				Union(NewSelector[SetOrder](db).Where(C("Id").EQ(2))).
+				Intersect(NewSelector[SetOrder](db).Where(C("Id").EQ(3))).Parenthesize(),
+			wantQuery: &Query{
+				SQL: "(SELECT * FROM `set_order` WHERE `id` = ? UNION SELECT * FROM `set_order` WHERE `id` = ?) " +
+					"INTERSECT (SELECT * FROM `set_order` WHERE `id` = ?);",
+				Args: []any{1, 2, 3},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestSetQuery_Postgres(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(Postgres))
+	q, err := NewSelector[SetOrder](db).Where(C("Id").EQ(1)).
+		Except(NewSelector[SetOrder](db).Where(C("Id").EQ(2))).
+		OrderBy(Asc("Id")).Limit(5).Build()
+	require.NoError(t, err)
+	assert.Equal(t, &Query{
+		SQL: `SELECT * FROM "set_order" WHERE "id" = $1 EXCEPT SELECT * FROM "set_order" WHERE "id" = $2 ` +
+			`ORDER BY "id" ASC LIMIT $3;`,
+		Args: []any{1, 2, 5},
+	}, q)
+}
+
+func TestSetQuery_GetMulti(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("setop", t)
+	require.NoError(t, NewCreater[SetOrder](db).Exec(ctx).Err())
+	require.NoError(t, NewInserter[SetOrder](db).Values(
+		&SetOrder{Id: 1, UserId: 1, Price: 100},
+		&SetOrder{Id: 2, UserId: 1, Price: 300},
+		&SetOrder{Id: 3, UserId: 2, Price: 200},
+		&SetOrder{Id: 4, UserId: 3, Price: 50},
+	).Exec(ctx).Err())
+
+	res, err := NewSelector[SetOrder](db).Where(C("UserId").EQ(1)).
+		Union(NewSelector[SetOrder](db).Where(C("Price").GT(150))).
+		OrderBy(Desc("Price")).Limit(2).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*SetOrder{
+		{Id: 2, UserId: 1, Price: 300},
+		{Id: 3, UserId: 2, Price: 200},
+	}, res)
+
+	res, err = NewSelector[SetOrder](db).Where(C("UserId").EQ(1)).
+		Intersect(NewSelector[SetOrder](db).Where(C("Price").GT(150))).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*SetOrder{{Id: 2, UserId: 1, Price: 300}}, res)
+
+	one, err := NewSelector[SetOrder](db).
+		Except(NewSelector[SetOrder](db).Where(C("Price").GT(60))).Get(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, &SetOrder{Id: 4, UserId: 3, Price: 50}, one)
+
+	res, err = NewSelector[SetOrder](db).OrderBy(Desc("Price")).Limit(1).
+		UnionAll(NewSelector[SetOrder](db).Where(C("Id").EQ(4))).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*SetOrder{
+		{Id: 2, UserId: 1, Price: 300},
+		{Id: 4, UserId: 3, Price: 50},
+	}, res)
+}
@@ -7,7 +7,8 @@ type TableReference interface {
 var _ TableReference = Table{}
 var _ TableReference = Join{}
 var _ TableReference = Subquery{}
-var _ TableReference = &Union{}
+var _ TableReference = &SetQuery[any]{}
+var _ TableReference = CommonTable{}
 
 type Table struct {
 	entity any
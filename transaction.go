@@ -18,11 +18,11 @@ func (t *Tx) getCore() core {
 }
 
 func (t *Tx) queryContext(ctx context.Context, sql string, args ...any) (*sql.Rows, error) {
-	return t.tx.QueryContext(ctx, sql, args)
+	return t.tx.QueryContext(ctx, sql, args...)
 }
 
 func (t *Tx) execContext(ctx context.Context, sql string, args ...any) (sql.Result, error) {
-	return t.tx.ExecContext(ctx, sql, args)
+	return t.tx.ExecContext(ctx, sql, args...)
 }
 
 func (t *Tx) Commit() error {
@@ -13,6 +13,10 @@ type Updater[T any] struct {
 	where   *predicates
 	assigns []Assignable
 	table   *T
+	// tbl 更新的表，可以是 JOIN，为 nil 的时候使用 T 的表
+	tbl     TableReference
+	orderBy []OrderBy
+	limit   int
 }
 
 func NewUpdater[T any](sess session) *Updater[T] {
@@ -23,7 +27,6 @@ func NewUpdater[T any](sess session) *Updater[T] {
 			core:     c,
 			buffer:   bytebufferpool.Get(),
 			aliasMap: make(map[string]int, 8),
-			quoter:   c.dialect.quoter(),
 		},
 	}
 }
@@ -38,13 +41,46 @@ func (u *Updater[T]) Where(ps ...Predicate) *Updater[T] {
 	return u
 }
 
+// With 定义一个 CTE，一般在 WHERE 的子查询里面通过 CTE(name) 引用它
+func (u *Updater[T]) With(name string, q QueryBuilder) *Updater[T] {
+	u.with(name, q, false)
+	return u
+}
+
+// WithRecursive 定义一个递归的 CTE
+func (u *Updater[T]) WithRecursive(name string, q QueryBuilder) *Updater[T] {
+	u.with(name, q, true)
+	return u
+}
+
+// Table 指定更新的表，例如 MySQL 的 UPDATE a JOIN b ON ... SET ...。
+// 这个时候可以通过 Table.Assign 更新指定的表，
+// 直接使用 Assign 的话会按照 JOIN 的顺序找到第一个有该字段的表
+func (u *Updater[T]) Table(tbl TableReference) *Updater[T] {
+	u.tbl = tbl
+	return u
+}
+
+// OrderBy 和 Limit 一起使用，可以分批更新。
+// 只有 MySQL 支持，并且不能和 JOIN 一起使用
+func (u *Updater[T]) OrderBy(orderBys ...OrderBy) *Updater[T] {
+	u.orderBy = orderBys
+	return u
+}
+
+// Limit 最多更新多少行，支持情况见 OrderBy
+func (u *Updater[T]) Limit(limit int) *Updater[T] {
+	u.limit = limit
+	return u
+}
+
 func (u *Updater[T]) Update(val *T) *Updater[T] {
 	u.table = val
 	return u
 }
 
 func (u *Updater[T]) Build() (*Query, error) {
-	defer bytebufferpool.Put(u.buffer)
+	defer u.release()
 	var err error
 	if u.model == nil {
 		u.model, err = u.r.Get(u.table)
@@ -52,8 +88,31 @@ func (u *Updater[T]) Build() (*Query, error) {
 			return nil, err
 		}
 	}
+	if err = u.buildWith(); err != nil {
+		return nil, err
+	}
+	_, isJoin := u.tbl.(Join)
+	limited := len(u.orderBy) > 0 || u.limit > 0
+	if isJoin && limited {
+		return nil, errs.ErrUpdateJoinWithLimit
+	}
+	if isJoin && !u.dialect.SupportUpdateJoin() {
+		return nil, errs.ErrUnsupportedUpdateJoin
+	}
+	if limited && !u.dialect.SupportUpdateLimit() {
+		return nil, errs.ErrUnsupportedUpdateLimit
+	}
+	if isJoin {
+		u.assignTables = joinedTables(u.tbl, nil)
+		// 没有指定表的列都是 T 的，JOIN 里面需要限定，否则同名的列会有歧义
+		if u.qualifier, err = u.targetQualifier(); err != nil {
+			return nil, err
+		}
+	}
 	u.writeString("UPDATE ")
-	u.quote(u.model.TableName)
+	if err = u.buildTable(u.tbl); err != nil {
+		return nil, err
+	}
 	if len(u.assigns) == 0 {
 		return nil, errs.ErrNoUpdatedColumns
 	}
@@ -72,11 +131,14 @@ func (u *Updater[T]) Build() (*Query, error) {
 				return nil, err
 			}
 		case Column:
-			fd, ok := u.model.FieldMap[a.name]
-			if !ok {
+			fd, err := u.buildAssignColumn(a.table, a.name)
+			if err != nil {
+				return nil, err
+			}
+			// 值来自 T，所以列也必须是 T 的
+			if u.model.FieldMap[a.name] != fd {
 				return nil, errs.NewErrUnknownField(a.name)
 			}
-			u.quote(fd.ColName)
 			u.writeString(" = ")
 			u.writePlaceholder()
 
@@ -96,10 +158,40 @@ func (u *Updater[T]) Build() (*Query, error) {
 			return nil, err
 		}
 	}
+	if len(u.orderBy) > 0 {
+		u.writeString(" ORDER BY ")
+		if err = u.buildOrderBy(u.orderBy, false); err != nil {
+			return nil, err
+		}
+	}
+	if u.limit > 0 {
+		err = u.dialect.BuildLimitOffset(&u.Builder, u.limit, 0, len(u.orderBy) > 0)
+		if err != nil {
+			return nil, err
+		}
+	}
 	u.end()
 	return &Query{SQL: u.buffer.String(), Args: u.args}, nil
 }
 
+// targetQualifier 返回 JOIN 里面 T 对应的表的别名，没有别名的时候是表名
+func (u *Updater[T]) targetQualifier() (string, error) {
+	for _, tbl := range u.assignTables {
+		m, err := u.r.Get(tbl.entity)
+		if err != nil {
+			return "", err
+		}
+		if m != u.model {
+			continue
+		}
+		if tbl.alias != "" {
+			return tbl.alias, nil
+		}
+		return m.TableName, nil
+	}
+	return "", nil
+}
+
 func AssignNotNilColumns(entity any) []Assignable {
 	return AssignColumns(entity,
 		func(typ reflect.StructField, val reflect.Value) bool {
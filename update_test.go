@@ -122,6 +122,138 @@ func TestUpdater_Build(t *testing.T) {
 				Args: []interface{}{int64(13)},
 			},
 		},
+		{
+			// SQLite 默认没有开启 SQLITE_ENABLE_UPDATE_DELETE_LIMIT
+			name: "unsupported limit",
+			u: NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).Set(C("Age")).
+				OrderBy(Asc("Id")).Limit(100),
+			wantErr: errs.ErrUnsupportedUpdateLimit,
+		},
+		{
+			name: "unsupported join",
+			u: func() QueryBuilder {
+				t1 := TableOf(&TestModel{})
+				t2 := TableOf(&JoinOrder{})
+				return NewUpdater[TestModel](db).
+					Table(t1.Join(t2).On(t1.C("Id").EQ(t2.C("UserId")))).
+					Set(t2.Assign("Amount", 0))
+			}(),
+			wantErr: errs.ErrUnsupportedUpdateJoin,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.u.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.want, q)
+		})
+	}
+}
+
+func TestUpdater_MySQL_Build(t *testing.T) {
+	db := memoryDB(t, DBWithDialect(MySQL))
+	testCases := []struct {
+		name    string
+		u       QueryBuilder
+		want    *Query
+		wantErr error
+	}{
+		{
+			name: "order by limit",
+			u: NewUpdater[TestModel](db).Update(&TestModel{Age: 18}).Set(C("Age")).
+				Where(C("Age").LT(18)).OrderBy(Asc("Id")).Limit(100),
+			want: &Query{
+				SQL:  "UPDATE `test_model` SET `age` = ? WHERE `age` < ? ORDER BY `id` ASC LIMIT ?;",
+				Args: []any{int8(18), 18, 100},
+			},
+		},
+		{
+			name: "join",
+			u: func() QueryBuilder {
+				t1 := TableOf(&TestModel{}).As("t1")
+				t2 := TableOf(&JoinOrder{}).As("t2")
+				return NewUpdater[TestModel](db).Update(&TestModel{FirstName: "Tom"}).
+					Table(t1.Join(t2).On(t1.C("Id").EQ(t2.C("UserId")))).
+					// 没有指定表的时候使用第一个有该字段的表
+					Set(t2.Assign("Amount", 0), Assign("Age", 18), Assign("UserId", 3), C("FirstName")).
+					Where(t2.C("Amount").GT(100))
+			}(),
+			want: &Query{
+				SQL: "UPDATE (`test_model` AS `t1` JOIN `join_order` AS `t2` ON `t1`.`id` = `t2`.`user_id`) " +
+					"SET `t2`.`amount` = ?,`t1`.`age` = ?,`t2`.`user_id` = ?,`t1`.`first_name` = ? WHERE `t2`.`amount` > ?;",
+				Args: []any{0, 18, 3, "Tom", 100},
+			},
+		},
+		{
+			name: "join without alias",
+			u: func() QueryBuilder {
+				t1 := TableOf(&TestModel{})
+				t2 := TableOf(&JoinOrder{})
+				return NewUpdater[TestModel](db).
+					Table(t1.Join(t2).On(t1.C("Id").EQ(t2.C("UserId")))).
+					Set(t2.Assign("Amount", t2.C("Amount").Add(1)))
+			}(),
+			want: &Query{
+				SQL: "UPDATE (`test_model` JOIN `join_order` ON `test_model`.`id` = `join_order`.`user_id`) " +
+					"SET `join_order`.`amount` = `join_order`.`amount` + ?;",
+				Args: []any{1},
+			},
+		},
+		{
+			name: "join invalid column",
+			u: func() QueryBuilder {
+				t1 := TableOf(&TestModel{})
+				t2 := TableOf(&JoinOrder{})
+				return NewUpdater[TestModel](db).
+					Table(t1.Join(t2).On(t1.C("Id").EQ(t2.C("UserId")))).
+					Set(Assign("Invalid", 1))
+			}(),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			// 值来自 TestModel，所以不能更新其它表的列
+			name: "join column of other table",
+			u: func() QueryBuilder {
+				t1 := TableOf(&TestModel{})
+				t2 := TableOf(&JoinOrder{})
+				return NewUpdater[TestModel](db).Update(&TestModel{}).
+					Table(t1.Join(t2).On(t1.C("Id").EQ(t2.C("UserId")))).
+					Set(C("Amount"))
+			}(),
+			wantErr: errs.NewErrUnknownField("Amount"),
+		},
+		{
+			// 两张表都有 id，没有指定表的列属于 T
+			name: "join with shared column",
+			u: func() QueryBuilder {
+				t1 := TableOf(&TestModel{}).As("t1")
+				t2 := TableOf(&JoinOrder{})
+				return NewUpdater[TestModel](db).
+					Table(t1.Join(t2).On(C("Id").EQ(t2.C("UserId")))).
+					Set(t2.Assign("Amount", C("Age"))).
+					Where(C("Id").EQ(1).And(t2.C("Id").GT(10)))
+			}(),
+			want: &Query{
+				SQL: "UPDATE (`test_model` AS `t1` JOIN `join_order` ON `t1`.`id` = `join_order`.`user_id`) " +
+					"SET `join_order`.`amount` = `t1`.`age` WHERE (`t1`.`id` = ?) AND (`join_order`.`id` > ?);",
+				Args: []any{1, 10},
+			},
+		},
+		{
+			// MySQL 不允许在 JOIN 里面使用 ORDER BY 和 LIMIT
+			name: "join with limit",
+			u: func() QueryBuilder {
+				t1 := TableOf(&TestModel{})
+				t2 := TableOf(&JoinOrder{})
+				return NewUpdater[TestModel](db).
+					Table(t1.Join(t2).On(t1.C("Id").EQ(t2.C("UserId")))).
+					Set(t2.Assign("Amount", 0)).Limit(10)
+			}(),
+			wantErr: errs.ErrUpdateJoinWithLimit,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
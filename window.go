@@ -0,0 +1,80 @@
+package orm
+
+import "strconv"
+
+// WindowExpr 代表窗口函数，例如
+// Sum("Amount").Over().PartitionBy(C("UserId")).OrderBy(Asc("Id")).As("total")
+// 会生成 SUM(`amount`) OVER (PARTITION BY `user_id` ORDER BY `id` ASC) AS `total`。
+// 注意 MySQL 从 8.0 开始，SQLite 从 3.25 开始才支持窗口函数
+type WindowExpr struct {
+	fn          Expression
+	partitionBy []Expression
+	orderBy     []OrderBy
+	alias       string
+}
+
+func (w WindowExpr) expr() {}
+
+func (w WindowExpr) selectedAlias() string {
+	return w.alias
+}
+
+func (w WindowExpr) fieldName() string {
+	return ""
+}
+
+func (w WindowExpr) target() TableReference {
+	return nil
+}
+
+func (w WindowExpr) As(alias string) WindowExpr {
+	w.alias = alias
+	return w
+}
+
+// PartitionBy 设置 PARTITION BY 部分
+func (w WindowExpr) PartitionBy(cols ...Expression) WindowExpr {
+	w.partitionBy = cols
+	return w
+}
+
+// OrderBy 设置窗口里面的 ORDER BY 部分
+func (w WindowExpr) OrderBy(orderBys ...OrderBy) WindowExpr {
+	w.orderBy = orderBys
+	return w
+}
+
+// Over 将聚合函数作为窗口函数使用
+func (a Aggregate) Over() WindowExpr {
+	return WindowExpr{fn: a}
+}
+
+// Over 将函数作为窗口函数使用，一般配合 RowNumber 这些函数
+func (f FuncExpr) Over() WindowExpr {
+	return WindowExpr{fn: f}
+}
+
+// RowNumber 分区内的行号，从 1 开始
+func RowNumber() FuncExpr {
+	return Func("ROW_NUMBER")
+}
+
+// Rank 分区内的排名，相同的值排名相同，并且会跳过后续的排名
+func Rank() FuncExpr {
+	return Func("RANK")
+}
+
+// DenseRank 和 Rank 一样，但是不会跳过排名
+func DenseRank() FuncExpr {
+	return Func("DENSE_RANK")
+}
+
+// Lag 当前行之前第 offset 行的 col
+func Lag(col Expression, offset int) FuncExpr {
+	return Func("LAG", col, Raw(strconv.Itoa(offset)))
+}
+
+// Lead 当前行之后第 offset 行的 col
+func Lead(col Expression, offset int) FuncExpr {
+	return Func("LEAD", col, Raw(strconv.Itoa(offset)))
+}
@@ -0,0 +1,126 @@
+package orm
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"orm/internal/errs"
+	"testing"
+)
+
+type WindowOrder struct {
+	Id     int64 `orm:"pk"`
+	UserId int64
+	Price  int
+}
+
+func TestWindow_Build(t *testing.T) {
+	db := memoryDB(t)
+	testCases := []struct {
+		name      string
+		q         QueryBuilder
+		wantQuery *Query
+		wantErr   error
+	}{
+		{
+			name: "row number",
+			q: NewSelector[WindowOrder](db).Select(C("Id"),
+				RowNumber().Over().PartitionBy(C("UserId")).OrderBy(Desc("Price"), Asc("Id")).As("rn")),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,ROW_NUMBER() OVER (PARTITION BY `user_id` ORDER BY `price` DESC,`id` ASC) AS `rn` " +
+					"FROM `window_order`;",
+			},
+		},
+		{
+			name: "aggregate",
+			q: NewSelector[WindowOrder](db).Select(C("Id"),
+				Sum("Price").Over().PartitionBy(C("UserId")).OrderBy(Asc("Id")).As("total"),
+				Count("Id").Over().As("cnt")),
+			wantQuery: &Query{
+				SQL: "SELECT `id`,SUM(`price`) OVER (PARTITION BY `user_id` ORDER BY `id` ASC) AS `total`," +
+					"COUNT(`id`) OVER () AS `cnt` FROM `window_order`;",
+			},
+		},
+		{
+			name: "rank lag lead",
+			q: NewSelector[WindowOrder](db).Select(
+				Rank().Over().OrderBy(Desc("Price")).As("r"),
+				DenseRank().Over().OrderBy(Desc("Price")).As("dr"),
+				Lag(C("Price"), 1).Over().OrderBy(Asc("Id")).As("prev"),
+				Lead(C("Price"), 2).Over().PartitionBy(C("UserId")).As("next")),
+			wantQuery: &Query{
+				SQL: "SELECT RANK() OVER (ORDER BY `price` DESC) AS `r`," +
+					"DENSE_RANK() OVER (ORDER BY `price` DESC) AS `dr`," +
+					"LAG(`price`,1) OVER (ORDER BY `id` ASC) AS `prev`," +
+					"LEAD(`price`,2) OVER (PARTITION BY `user_id`) AS `next` FROM `window_order`;",
+			},
+		},
+		{
+			name: "subquery",
+			q: func() QueryBuilder {
+				sub := NewSelector[WindowOrder](db).Select(C("Id"), C("UserId"),
+					RowNumber().Over().PartitionBy(C("UserId")).OrderBy(Desc("Price")).As("rn")).
+					Where(C("Price").GT(10)).AsSubquery("t")
+				return NewSelector[WindowOrder](db).Select(sub.C("Id"), sub.C("UserId")).
+					From(sub).Where(sub.C("rn").EQ(1))
+			}(),
+			wantQuery: &Query{
+				SQL: "SELECT `t`.`id`,`t`.`user_id` FROM (SELECT `id`,`user_id`," +
+					"ROW_NUMBER() OVER (PARTITION BY `user_id` ORDER BY `price` DESC) AS `rn` " +
+					"FROM `window_order` WHERE `price` > ?) AS `t` WHERE `t`.`rn` = ?;",
+				Args: []any{10, 1},
+			},
+		},
+		{
+			name: "invalid partition column",
+			q: NewSelector[WindowOrder](db).
+				Select(RowNumber().Over().PartitionBy(C("Invalid"))),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+		{
+			name: "invalid subquery column",
+			q: func() QueryBuilder {
+				sub := NewSelector[WindowOrder](db).Select(C("Id"),
+					RowNumber().Over().As("rn")).AsSubquery("t")
+				return NewSelector[WindowOrder](db).From(sub).Where(sub.C("Invalid").EQ(1))
+			}(),
+			wantErr: errs.NewErrUnknownField("Invalid"),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			q, err := tc.q.Build()
+			assert.Equal(t, tc.wantErr, err)
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.wantQuery, q)
+		})
+	}
+}
+
+func TestWindow_Exec(t *testing.T) {
+	ctx := context.Background()
+	db := memoryDBWithDB("window", t)
+	require.NoError(t, NewCreater[WindowOrder](db).Exec(ctx).Err())
+	require.NoError(t, NewInserter[WindowOrder](db).Values(
+		&WindowOrder{Id: 1, UserId: 1, Price: 100},
+		&WindowOrder{Id: 2, UserId: 1, Price: 300},
+		&WindowOrder{Id: 3, UserId: 2, Price: 200},
+		&WindowOrder{Id: 4, UserId: 2, Price: 50},
+	).Exec(ctx).Err())
+
+	// 每个用户价格最高的订单
+	sub := NewSelector[WindowOrder](db).Select(C("Id"), C("UserId"), C("Price"),
+		RowNumber().Over().PartitionBy(C("UserId")).OrderBy(Desc("Price")).As("rn")).
+		AsSubquery("t")
+	res, err := NewSelector[WindowOrder](db).
+		Select(sub.C("Id"), sub.C("UserId"), sub.C("Price")).
+		From(sub).Where(sub.C("rn").EQ(1)).
+		OrderBy(Asc(sub.C("UserId"))).GetMulti(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []*WindowOrder{
+		{Id: 2, UserId: 1, Price: 300},
+		{Id: 3, UserId: 2, Price: 200},
+	}, res)
+}